@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// clefMarker is the timestamp key that every CLEF event carries. It is checked
+// before decoding, so ordinary JSON log lines are not decoded twice.
+var clefMarker = []byte(`"@t"`)
+
+// clefDefaultLevel is the level of a CLEF event without "@l".
+const clefDefaultLevel = "Information"
+
+// parseClefLogLine parses Serilog's Compact Log Event Format, the structured
+// JSON written by .NET services. The message is rendered from the "@mt" message
+// template, unless the event carries a pre-rendered "@m". The "@l" level
+// defaults to Information, and the "@x" exception becomes the stacktrace.
+func parseClefLogLine(line []byte, tsField string) (map[string]any, error) {
+	if !bytes.Contains(line, clefMarker) {
+		return nil, ErrUnparseable
+	}
+
+	event := map[string]any{}
+	if err := json.Unmarshal(line, &event); err != nil {
+		return nil, err
+	}
+
+	ts, err := getString(event, "@t")
+	if err != nil {
+		return nil, ErrUnparseable
+	}
+
+	mt, mtErr := getString(event, "@mt")
+	msg, mErr := getString(event, "@m")
+	if mtErr != nil && mErr != nil {
+		return nil, ErrUnparseable
+	}
+
+	level, err := getString(event, "@l")
+	if err != nil {
+		level = clefDefaultLevel
+	}
+
+	exception, _ := getString(event, "@x")
+
+	var renderings []any
+	if rs, ok := event["@r"].([]any); ok {
+		renderings = rs
+	}
+
+	lineData := make(map[string]any, len(event))
+	for k, v := range event {
+		switch {
+		case strings.HasPrefix(k, "@@"):
+			lineData[k[1:]] = v
+		case strings.HasPrefix(k, "@"):
+		default:
+			lineData[k] = v
+		}
+	}
+
+	if mErr != nil {
+		msg = renderClefTemplate(mt, lineData, renderings)
+	}
+
+	lineData[tsField] = ts
+	lineData[lvlField] = level
+	lineData[msgField] = msg
+	if exception != "" {
+		lineData["stacktrace"] = exception
+	}
+	if id, ok := event["@i"]; ok {
+		lineData["event_id"] = id
+	}
+
+	convertGenericTimestampToTime(lineData, tsField)
+
+	return lineData, nil
+}
+
+// renderClefTemplate renders a Serilog message template, replacing each
+// {Property} hole with the value of that property. Holes may carry the @ and $
+// capturing operators, an alignment, and a format. Formatting is done by .NET
+// when the event is written, so a hole with a format takes its text from the
+// next "@r" rendering, when there is one. Holes naming a missing property are
+// left as they are.
+func renderClefTemplate(mt string, props map[string]any, renderings []any) string {
+	out := &strings.Builder{}
+	nextRendering := 0
+	for len(mt) > 0 {
+		i := strings.IndexAny(mt, "{}")
+		if i < 0 {
+			out.WriteString(mt)
+			break
+		}
+
+		out.WriteString(mt[:i])
+		mt = mt[i:]
+
+		// doubled braces are escapes for literal braces
+		if len(mt) > 1 && mt[1] == mt[0] {
+			out.WriteByte(mt[0])
+			mt = mt[2:]
+			continue
+		}
+
+		end := strings.IndexByte(mt, '}')
+		if mt[0] == '}' || end < 0 {
+			out.WriteByte(mt[0])
+			mt = mt[1:]
+			continue
+		}
+
+		hole := mt[1:end]
+		mt = mt[end+1:]
+
+		name, _, hasFormat := strings.Cut(hole, ":")
+		name, alignment, _ := strings.Cut(name, ",")
+		name = strings.TrimLeft(name, "@$")
+
+		rendered, ok := "", false
+		if hasFormat && nextRendering < len(renderings) {
+			rendered, ok = renderings[nextRendering].(string)
+			nextRendering++
+		}
+		if !ok {
+			v, hasProp := props[name]
+			if !hasProp {
+				out.WriteString("{" + hole + "}")
+				continue
+			}
+			rendered = renderClefValue(v)
+		}
+
+		if width, err := strconv.Atoi(alignment); err == nil {
+			rendered = fmt.Sprintf("%*s", width, rendered)
+		}
+
+		out.WriteString(rendered)
+	}
+
+	return out.String()
+}
+
+// renderClefValue renders a property value for a template hole. Strings appear
+// without quotes, and structured values as JSON.
+func renderClefValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64, bool:
+		return fmt.Sprint(v)
+	case nil:
+		return "null"
+	}
+
+	bs, _ := json.Marshal(v)
+	return string(bs)
+}
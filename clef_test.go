@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseClefLogLine checks the mapping of the CLEF reserved properties onto
+// the entry.
+func TestParseClefLogLine(t *testing.T) {
+	line := `{"@t":"2026-08-13T14:22:03.1170000Z","@mt":"User {UserId} logged in from {@Client}","@l":"Warning","@x":"System.Exception: boom\n   at Login()","@i":"a1b2c3d4","UserId":42,"Client":{"Ip":"10.0.0.7"},"@@version":2}`
+
+	lineData, err := parseClefLogLine([]byte(line), "ts")
+	require.NoError(t, err, "CLEF line parses")
+
+	assert.Equal(t, time.Date(2026, 8, 13, 14, 22, 3, 117_000_000, time.UTC), lineData["ts"].(time.Time).UTC(), "@t is the timestamp")
+	assert.Equal(t, "Warning", lineData["level"], "@l is the level")
+	assert.Equal(t, `User 42 logged in from {"Ip":"10.0.0.7"}`, lineData["msg"], "@mt rendered with the properties")
+	assert.Equal(t, "System.Exception: boom\n   at Login()", lineData["stacktrace"], "@x is the stacktrace")
+	assert.Equal(t, "a1b2c3d4", lineData["event_id"], "@i is the event ID")
+	assert.Equal(t, float64(42), lineData["UserId"], "properties kept as fields")
+	assert.Equal(t, float64(2), lineData["@version"], "escaped @@ property unescaped")
+	assert.NotContains(t, lineData, "@mt", "reserved properties removed")
+}
+
+// TestParseClefLogLineDefaults covers the optional reserved properties.
+func TestParseClefLogLineDefaults(t *testing.T) {
+	lineData, err := parseClefLogLine([]byte(`{"@t":"2026-08-13T14:22:03Z","@m":"already {rendered}"}`), "ts")
+	require.NoError(t, err, "CLEF line parses")
+
+	assert.Equal(t, "Information", lineData["level"], "level defaults to Information")
+	assert.Equal(t, "already {rendered}", lineData["msg"], "@m is used as-is")
+	assert.NotContains(t, lineData, "stacktrace", "no stacktrace without @x")
+
+	_, err = parseClefLogLine([]byte(`{"@t":"2026-08-13T14:22:03Z","msg":"not CLEF"}`), "ts")
+	assert.ErrorIs(t, err, ErrUnparseable, "CLEF requires a message or template")
+
+	_, err = parseClefLogLine([]byte(`{"ts":"2026-08-13T14:22:03Z","msg":"plain"}`), "ts")
+	assert.ErrorIs(t, err, ErrUnparseable, "plain JSON is not CLEF")
+}
+
+func TestRenderClefTemplate(t *testing.T) {
+	props := map[string]any{
+		"Name":    "alice",
+		"Count":   3.0,
+		"Elapsed": 12.3456,
+		"Ok":      true,
+		"Tags":    []any{"a", "b"},
+	}
+
+	tests := map[string]string{
+		"Hello {Name}":                        "Hello alice",
+		"{Count} items, ok={Ok}":              "3 items, ok=true",
+		"tags {$Tags}":                        `tags ["a","b"]`,
+		"[{Name,8}] [{Name,-8}]":              "[   alice] [alice   ]",
+		"literal {{Name}} and }}":             "literal {Name} and }",
+		"missing {Nope} stays":                "missing {Nope} stays",
+		"unterminated {Name":                  "unterminated {Name",
+		"took {Elapsed:0.00} ms for {Name:l}": "took 12.35 ms for alice",
+	}
+
+	renderings := []any{"12.35", "alice"}
+	for mt, want := range tests {
+		assert.Equal(t, want, renderClefTemplate(mt, props, renderings), "rendering %q", mt)
+	}
+}
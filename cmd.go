@@ -6,11 +6,21 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
 )
 
+// maxLineSize is the longest input line logfmt will read. Batch formats like
+// OTLP put many entries on one line, which easily exceeds bufio's 64 KiB
+// default.
+const maxLineSize = 16 * 1024 * 1024
+
 //go:embed version.txt
 var Version string
 
@@ -20,8 +30,21 @@ var (
 	outputFile             string
 	appendToFile           bool
 	colorize               string
+	colorDepth             string
+	theme                  string
+	outputFormat           string
+	columns                []string
+	overflow               string
+	expand                 string
+	timeZone               string
+	timeFormat             string
+	relativeTime           string
 	highlightWorryWords    bool
+	worryIn                []string
+	escalateWorries        bool
+	minLevel               string
 	experimentalAccessLogs bool
+	decodeEmbedded         bool
 	tsField                string
 	msgField               string
 	msgFormat              string
@@ -31,14 +54,29 @@ var (
 	version                bool
 	showNull               bool
 	extractFields          []string
+	identityFields         []string
 	helpConfig             bool
 	initConfig             string
 	initConfigHome         bool
 )
 
 func init() {
-	// Initialize command
-	cmd = &cobra.Command{
+	// Load configuration first
+	var err error
+	config, err = LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load config: %v\n", err)
+		config = DefaultConfig()
+	}
+
+	cmd = newCommand(config)
+}
+
+// newCommand builds the command, with its flags defaulting to the
+// configuration. Defining the flags sets the variables they are bound to, so
+// this also resets every option to the configured value.
+func newCommand(config *Config) *cobra.Command {
+	cmd := &cobra.Command{
 		Use:   "logfmt [ <input-file> ]",
 		Short: "Format standard input for the named input-file",
 		Long:  `Format mixed text/JSON log output to be more human-readable.`,
@@ -61,31 +99,40 @@ Configuration:
 		return err
 	})
 
-	// Load configuration first
-	var err error
-	config, err = LoadConfig()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: failed to load config: %v\n", err)
-		config = DefaultConfig()
-	}
-
 	// Define flags with defaults from config
 	cmd.Flags().StringVarP(&outputFile, "output", "o", config.OutputFile, "output file write to or - for standard output")
 	cmd.Flags().BoolVarP(&appendToFile, "append", "a", config.AppendToFile, "set to append to existing output")
 	cmd.Flags().StringVarP(&colorize, "color", "c", config.Colorize, "set the colorize mode (auto, on, off)")
+	cmd.Flags().StringVar(&colorDepth, "color-depth", config.ColorDepth, "set the number of colors to use (auto, 16, 256, truecolor)")
+	cmd.Flags().StringVar(&theme, "theme", config.Theme, "set the color theme (dark, light, solarized-dark, solarized-light, high-contrast, colorblind, auto)")
+	cmd.Flags().StringVar(&outputFormat, "output-format", config.OutputFormat, "set the output format (console, aligned, logfmt, json, csv, tsv, html)")
+	cmd.Flags().StringSliceVar(&columns, "columns", config.Columns, "set the comma-separated fields to write as columns in csv and tsv output")
+	cmd.Flags().StringVar(&expand, "expand", config.Expand, "set how trailing fields are shown: inline, tree, or a number of characters beyond which a tree is used")
+	cmd.Flags().StringVar(&overflow, "overflow", config.Overflow, "set how long lines fit the terminal in aligned output (wrap, truncate)")
+	cmd.Flags().StringVar(&timeZone, "tz", config.TimeZone, "set the time zone to show timestamps in (local, UTC, or a name like America/Chicago)")
+	cmd.Flags().StringVar(&timeFormat, "time-format", config.TimeFormat, "set the timestamp format (a Go layout, a strftime pattern, or rfc3339, rfc3339nano, short, time-only, kitchen, stamp)")
+	cmd.Flags().StringVar(&relativeTime, "relative", config.Relative, "show the time since the first line or the previous line instead of timestamps (first, prev, off)")
+	cmd.Flags().Lookup("relative").NoOptDefVal = "first"
 	cmd.Flags().BoolVar(&highlightWorryWords, "highlight-worry-words", config.HighlightWorryWords, "enable highlighting of worry-words")
+	cmd.Flags().StringSliceVar(&worryIn, "worry-in", config.WorryIn, "set the comma-separated places to highlight worry-words in (message, extracted, data, raw)")
+	cmd.Flags().BoolVar(&escalateWorries, "escalate-worries", config.EscalateWorries, "raise the level of entries whose message has an err or crit worry-word to error or fatal")
+	cmd.Flags().StringVar(&minLevel, "min-level", config.MinLevel, "hide entries less severe than this level, like warn")
 	cmd.Flags().BoolVar(&experimentalAccessLogs, "experimental-access-logs", config.ExperimentalAccessLogs, "enable access log parsing")
+	cmd.Flags().BoolVar(&decodeEmbedded, "decode-embedded", config.DecodeEmbedded, "decode JSON embedded in string fields and at the end of messages")
 	cmd.Flags().StringVarP(&tsField, "timestamp-field", "t", config.TimestampField, "set the timestamp field name")
 	cmd.Flags().StringVar(&msgField, "message-field", config.MessageField, "set the message field name")
 	cmd.Flags().StringVar(&lvlField, "level-field", config.LevelField, "set the level field name")
 	cmd.Flags().StringVar(&callerField, "caller-field", config.CallerField, "set the caller field name")
-	cmd.Flags().StringArrayVarP(&trimFields, "trim-field", "T", config.TrimFields, "set fields to trim from the output")
+	cmd.Flags().StringArrayVarP(&trimFields, "trim-field", "T", config.TrimFields, "set fields to trim from the output, or +field and -field to add to and remove from the defaults")
 	cmd.Flags().BoolVar(&version, "version", false, "print the version and exit")
 	cmd.Flags().BoolVar(&showNull, "show-null", config.ShowNull, "show null values in output")
-	cmd.Flags().StringArrayVarP(&extractFields, "extract-field", "X", config.ExtractFields, "set fields to extract from the output for display")
+	cmd.Flags().StringArrayVarP(&extractFields, "extract-field", "X", config.ExtractFields, "set fields to extract from the output for display, or +field and -field to add to and remove from the defaults")
+	cmd.Flags().StringArrayVarP(&identityFields, "identity-field", "I", config.IdentityFields, "set fields whose values are colored by a hash of the value, like request IDs")
 	cmd.Flags().BoolVar(&helpConfig, "help-config", false, "show comprehensive configuration help")
 	cmd.Flags().StringVar(&initConfig, "init-config", "", "initialize configuration file with specified filename")
 	cmd.Flags().BoolVar(&initConfigHome, "init-config-home", false, "initialize configuration file in home directory (~/.logfmt.yaml)")
+
+	return cmd
 }
 
 // setupInput sets up the input file handle based on command-line input or returns err.
@@ -144,6 +191,21 @@ func checkColorizeMode() error {
 	return fmt.Errorf("invalid --color mode %q: expected one of %s", colorize, strings.Join(colorModes, ", "))
 }
 
+// setupPalette builds the palette from the theme and the colors in the
+// configuration, falling back to the theme alone if the configured colors are
+// invalid. The output is checked for the background in auto theme mode.
+func setupPalette(output io.Writer) Palette {
+	palette := setupTheme(output)
+	if config != nil {
+		if customPalette, err := config.GetCustomPalette(palette); err == nil {
+			palette = customPalette
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: failed to load custom colors: %v\n", err)
+		}
+	}
+	return palette
+}
+
 // setupColorizer builds the colorizer for output. In "auto" mode, color is
 // enabled only when output is a terminal, which requires output to be the
 // *os.File returned by setupOutput. Wrapping it (in a bufio.Writer, say) would
@@ -152,28 +214,118 @@ func checkColorizeMode() error {
 // The mode is expected to have passed checkColorizeMode already, so an
 // unrecognized value falls through to auto rather than being rejected here.
 func setupColorizer(output io.Writer) *SugaredColorizer {
-	// Get custom palette from config
-	palette := DefaultPalette
-	if config != nil {
-		if customPalette, err := config.GetCustomPalette(); err == nil {
-			palette = customPalette
-		} else {
-			fmt.Fprintf(os.Stderr, "Warning: failed to load custom colors: %v\n", err)
-		}
-	}
+	palette := setupPalette(output)
 
 	var colorizer *SugaredColorizer
 	switch colorize {
 	case "off":
 		colorizer = NewSugaredColorizer(&ColorOff{})
 	case "on":
-		colorizer = NewSugaredColorizer(NewColorOn(palette))
+		colorizer = NewSugaredColorizer(NewColorOn(palette, setupColorDepth()))
 	default:
-		colorizer = NewSugaredColorizer(NewColorAuto(palette, output))
+		colorizer = NewSugaredColorizer(NewColorAuto(palette, setupColorDepth(), output))
 	}
 	return colorizer
 }
 
+// outputFormats are the accepted --output-format values. An empty value means
+// the format was never set, which behaves as console.
+var outputFormats = []string{"console", "aligned", "logfmt", "json", "csv", "tsv", "html"}
+
+// checkOutputFormat rejects an unrecognized --output-format value.
+func checkOutputFormat() error {
+	if outputFormat == "" || slices.Contains(outputFormats, outputFormat) {
+		return nil
+	}
+	return fmt.Errorf("invalid --output-format %q: expected one of %s", outputFormat, strings.Join(outputFormats, ", "))
+}
+
+// overflowModes are the accepted --overflow values. An empty value means the
+// mode was never set, which behaves as wrap.
+var overflowModes = []string{"wrap", "truncate"}
+
+// checkOverflowMode rejects an unrecognized --overflow value.
+func checkOverflowMode() error {
+	if overflow == "" || slices.Contains(overflowModes, overflow) {
+		return nil
+	}
+	return fmt.Errorf("invalid --overflow mode %q: expected one of %s", overflow, strings.Join(overflowModes, ", "))
+}
+
+// setupWidth reports the width of the terminal for the aligned format. If the
+// output is a terminal, the width is updated whenever the terminal is resized.
+// Otherwise, it is taken from COLUMNS, or is 0, meaning unlimited.
+func setupWidth(output io.Writer) *atomic.Int64 {
+	width := &atomic.Int64{}
+
+	if w := terminalWidth(output); w > 0 {
+		width.Store(int64(w))
+
+		winch := make(chan os.Signal, 1)
+		signal.Notify(winch, unix.SIGWINCH)
+		go func() {
+			for range winch {
+				if w := terminalWidth(output); w > 0 {
+					width.Store(int64(w))
+				}
+			}
+		}()
+
+		return width
+	}
+
+	if w, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && w > 0 {
+		width.Store(int64(w))
+	}
+	return width
+}
+
+// setupTimeDisplay builds the display of timestamps from --tz, --time-format,
+// and --relative.
+func setupTimeDisplay() (*TimeDisplay, error) {
+	return NewTimeDisplay(timeZone, timeFormat, relativeTime)
+}
+
+// setupFormatter builds the formatter for the selected output format. Only the
+// console, aligned, and html formats are colorized. The html format is
+// colorized unless color is turned off outright, since it is not meant for a
+// terminal anyway. The timestamp field is always trimmed, since every format
+// shows it on its own. The colorized formats show timestamps through times and
+// show <no value> for an entry without a message; the others write timestamps
+// for machines to read and an empty message instead.
+func setupFormatter(output io.Writer, times *TimeDisplay) LineFormatter {
+	trims := append(slices.Clone(trimFields), tsField)
+	shownFormat, dataFormat := msgFormat, msgFormat
+	if msgFormat == "" {
+		shownFormat = fmt.Sprintf("{{index . %q}}", msgField)
+		dataFormat = fmt.Sprintf("{{with index . %q}}{{.}}{{end}}", msgField)
+	}
+
+	switch outputFormat {
+	case "aligned":
+		return NewAlignedFormatter(output, setupColorizer(output), tsField, shownFormat, trims, overflow == "truncate", setupWidth(output), times)
+	case "logfmt":
+		return NewLogfmtFormatter(output, tsField, dataFormat, trims)
+	case "json":
+		return NewJSONFormatter(output, tsField, dataFormat, trims)
+	case "csv":
+		return NewCSVFormatter(output, ',', tsField, dataFormat, columns)
+	case "tsv":
+		return NewCSVFormatter(output, '\t', tsField, dataFormat, columns)
+	case "html":
+		return NewHTMLFormatter(output, setupPalette(output), colorize != "off", tsField, shownFormat, trims, times)
+	default:
+		return NewConsoleFormatter(output, setupColorizer(output), tsField, shownFormat, trims, times)
+	}
+}
+
+// setupFields applies --trim-field and --extract-field to the fields configured,
+// so that +name and -name add to and remove from them. See mergeFields.
+func setupFields() {
+	trimFields = mergeFields(config.TrimFields, trimFields)
+	extractFields = mergeFields(config.ExtractFields, extractFields)
+}
+
 // formatLogLines ingests a file or standard input, breaks the input into lines,
 // and attempts to parse each line. If parsing is successful, if formats that
 // log line prettily. If parsing fails, the line is output as-is. It keeps going
@@ -205,11 +357,25 @@ func formatLogLines(cmd *cobra.Command, args []string) {
 		os.Exit(0)
 	}
 
-	setupWorries(config)
+	setupFields()
+	onErrReportAndQuit(setupWorries(config))
+	onErrReportAndQuit(setupRenderRules(config))
+	onErrReportAndQuit(setupLevels(config))
 
-	// Check this before opening the output file, so an invalid mode does not
-	// truncate an existing file on the way to the error.
+	// Check these, and set up the time display, before opening the output
+	// file, so an invalid value does not truncate an existing file on the way
+	// to the error.
 	onErrReportAndQuit(checkColorizeMode())
+	onErrReportAndQuit(checkColorDepth())
+	onErrReportAndQuit(checkTheme())
+	onErrReportAndQuit(checkOutputFormat())
+	onErrReportAndQuit(checkOverflowMode())
+	onErrReportAndQuit(checkExpandMode())
+	onErrReportAndQuit(checkWorryIn())
+	onErrReportAndQuit(checkMinLevel())
+
+	times, err := setupTimeDisplay()
+	onErrReportAndQuit(err)
 
 	input, err := setupInput(args)
 	onErrReportAndQuit(err)
@@ -217,20 +383,39 @@ func formatLogLines(cmd *cobra.Command, args []string) {
 	output, err := setupOutput()
 	onErrReportAndQuit(err)
 
-	colorizer := setupColorizer(output)
+	formatter := setupFormatter(output, times)
 
 	buffed := bufio.NewScanner(input)
+	buffed.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLineSize)
 	for buffed.Scan() {
 		line := buffed.Text()
-		lineData, err := parseLogLine(buffed.Bytes(), tsField)
+		entries, err := parseLogEntries(buffed.Bytes(), tsField)
 		if err != nil {
-			outputRawLogLine(output, colorizer, line)
-		} else {
-			trimFields = append(trimFields, tsField)
-			if msgFormat == "" {
-				msgFormat = fmt.Sprintf("{{index . %q}}", msgField)
+			formatter.FormatRaw(line)
+			continue
+		}
+
+		for _, lineData := range entries {
+			if decodeEmbedded {
+				decodeEmbeddedJSON(lineData)
 			}
-			outputFormattedLogLine(output, colorizer, lineData, tsField, msgFormat, trimFields)
+
+			if escalateWorries {
+				escalateLevel(lineData)
+			}
+
+			if !meetsMinLevel(lineData) {
+				continue
+			}
+
+			formatter.FormatEntry(lineData)
 		}
 	}
+
+	// Flush what was read before reporting a read error, such as a line
+	// longer than maxLineSize, so the output stops where the input did.
+	onErrReportAndQuit(formatter.Close())
+	if err := buffed.Err(); err != nil {
+		onErrReportAndQuit(fmt.Errorf("failed to read input: %v", err))
+	}
 }
@@ -37,6 +37,52 @@ func TestSetupColorizerMode(t *testing.T) {
 	}
 }
 
+// TestSetupColorizerEnvironment covers how NO_COLOR, FORCE_COLOR, CLICOLOR, and
+// CLICOLOR_FORCE turn color on and off in auto mode, their precedence over each
+// other, and that an explicit --color on or off overrides them all.
+func TestSetupColorizerEnvironment(t *testing.T) {
+	orig := colorize
+	t.Cleanup(func() { colorize = orig })
+
+	tests := []struct {
+		name  string
+		mode  string
+		env   map[string]string
+		color bool
+	}{
+		{"not a terminal", "auto", nil, false},
+		{"NO_COLOR", "auto", map[string]string{"NO_COLOR": "1"}, false},
+		{"FORCE_COLOR", "auto", map[string]string{"FORCE_COLOR": "1"}, true},
+		{"FORCE_COLOR level", "auto", map[string]string{"FORCE_COLOR": "3"}, true},
+		{"FORCE_COLOR=0", "auto", map[string]string{"FORCE_COLOR": "0"}, false},
+		{"FORCE_COLOR=false", "auto", map[string]string{"FORCE_COLOR": "false"}, false},
+		{"CLICOLOR_FORCE", "auto", map[string]string{"CLICOLOR_FORCE": "1"}, true},
+		{"CLICOLOR_FORCE=0", "auto", map[string]string{"CLICOLOR_FORCE": "0"}, false},
+		{"CLICOLOR=0", "auto", map[string]string{"CLICOLOR": "0"}, false},
+		{"CLICOLOR=1 without a terminal", "auto", map[string]string{"CLICOLOR": "1"}, false},
+		{"unset mode is auto", "", map[string]string{"FORCE_COLOR": "1"}, true},
+		{"NO_COLOR beats FORCE_COLOR", "auto", map[string]string{"NO_COLOR": "1", "FORCE_COLOR": "1"}, false},
+		{"FORCE_COLOR=0 beats CLICOLOR_FORCE", "auto", map[string]string{"FORCE_COLOR": "0", "CLICOLOR_FORCE": "1"}, false},
+		{"CLICOLOR_FORCE beats CLICOLOR=0", "auto", map[string]string{"CLICOLOR_FORCE": "1", "CLICOLOR": "0"}, true},
+		{"--color=on beats NO_COLOR", "on", map[string]string{"NO_COLOR": "1"}, true},
+		{"--color=off beats FORCE_COLOR", "off", map[string]string{"FORCE_COLOR": "1"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{"NO_COLOR", "FORCE_COLOR", "CLICOLOR", "CLICOLOR_FORCE"} {
+				t.Setenv(k, tt.env[k])
+			}
+			colorize = tt.mode
+
+			colorizer := setupColorizer(&bytes.Buffer{})
+
+			out := colorizer.C(ColorMessage, "hello")
+			assert.Equal(t, tt.color, out != "hello", "colored output: %q", out)
+		})
+	}
+}
+
 // TestCheckColorizeMode covers the accepted --color values and the rejection of
 // anything else, which previously fell through to auto and silently turned
 // color off on a typo.
@@ -132,3 +178,160 @@ func TestSetupColorizerAutoIsOffForNonTerminal(t *testing.T) {
 
 	assert.Equal(t, "boom", colorizer.C(ColorLevelError, "boom"), "no color when output is not a terminal")
 }
+
+// TestCheckOutputFormat covers the accepted --output-format values and the
+// rejection of anything else.
+func TestCheckOutputFormat(t *testing.T) {
+	orig := outputFormat
+	t.Cleanup(func() { outputFormat = orig })
+
+	for _, format := range []string{"console", "aligned", "logfmt", "json", "csv", "tsv", "html", ""} {
+		outputFormat = format
+		assert.NoError(t, checkOutputFormat(), "%q is a valid format", format)
+	}
+
+	for _, format := range []string{"bogus", "LOGFMT", "text"} {
+		outputFormat = format
+
+		err := checkOutputFormat()
+
+		require.Error(t, err, "%q is rejected", format)
+		assert.Contains(t, err.Error(), format, "error names the bad format")
+		assert.Contains(t, err.Error(), "console, aligned, logfmt, json, csv, tsv, html", "error lists the valid formats")
+	}
+}
+
+// TestSetupFormatter pins down which formatter each --output-format value
+// selects.
+func TestSetupFormatter(t *testing.T) {
+	orig := outputFormat
+	t.Cleanup(func() { outputFormat = orig })
+
+	tests := map[string]any{
+		"console": &ConsoleFormatter{},
+		"aligned": &AlignedFormatter{},
+		"logfmt":  &LogfmtFormatter{},
+		"json":    &JSONFormatter{},
+		"csv":     &CSVFormatter{},
+		"tsv":     &CSVFormatter{},
+		"html":    &HTMLFormatter{},
+		"":        &ConsoleFormatter{},
+	}
+
+	for format, want := range tests {
+		outputFormat = format
+
+		assert.IsType(t, want, setupFormatter(&bytes.Buffer{}, &TimeDisplay{}), "formatter type for --output-format=%q", format)
+	}
+}
+
+// TestCheckOverflowMode covers the accepted --overflow values and the rejection
+// of anything else.
+func TestCheckOverflowMode(t *testing.T) {
+	orig := overflow
+	t.Cleanup(func() { overflow = orig })
+
+	for _, mode := range []string{"wrap", "truncate", ""} {
+		overflow = mode
+		assert.NoError(t, checkOverflowMode(), "%q is a valid mode", mode)
+	}
+
+	overflow = "clip"
+
+	err := checkOverflowMode()
+
+	require.Error(t, err, "unknown mode is rejected")
+	assert.Contains(t, err.Error(), "wrap, truncate", "error lists the valid modes")
+}
+
+// TestSetupWidthFromColumns checks that COLUMNS sets the width when the output
+// is not a terminal.
+func TestSetupWidthFromColumns(t *testing.T) {
+	t.Setenv("COLUMNS", "100")
+	assert.Equal(t, int64(100), setupWidth(&bytes.Buffer{}).Load(), "width from COLUMNS")
+
+	t.Setenv("COLUMNS", "")
+	assert.Equal(t, int64(0), setupWidth(&bytes.Buffer{}).Load(), "unlimited without COLUMNS")
+}
+
+// TestMergeFields covers replacing, adding to, and removing from the default
+// field lists with plain, +, and - names.
+func TestMergeFields(t *testing.T) {
+	base := []string{"level", "msg", "error"}
+
+	tests := []struct {
+		name   string
+		fields []string
+		want   []string
+	}{
+		{"plain names replace", []string{"noisy"}, []string{"noisy"}},
+		{"empty replaces", []string{}, []string{}},
+		{"add", []string{"+password"}, []string{"level", "msg", "error", "password"}},
+		{"remove", []string{"-error"}, []string{"level", "msg"}},
+		{"add and remove", []string{"-msg", "+token"}, []string{"level", "error", "token"}},
+		{"add existing", []string{"+msg"}, []string{"level", "error", "msg"}},
+		{"remove missing", []string{"-nope"}, []string{"level", "msg", "error"}},
+		{"plain names with edits", []string{"a", "b", "+c", "-a"}, []string{"b", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, mergeFields(base, tt.fields))
+		})
+	}
+	assert.Equal(t, []string{"level", "msg", "error"}, base, "base is not modified")
+}
+
+// TestSetupFields checks that --trim-field and --extract-field edit the
+// configured fields, not only the built-in defaults.
+func TestSetupFields(t *testing.T) {
+	origConfig, origTrim, origExtract := config, trimFields, extractFields
+	t.Cleanup(func() { config, trimFields, extractFields = origConfig, origTrim, origExtract })
+
+	config = DefaultConfig()
+	config.TrimFields = []string{"level", "msg", "secret"}
+	trimFields = []string{"+token", "-msg"}
+	extractFields = []string{"details"}
+
+	setupFields()
+
+	assert.Equal(t, []string{"level", "secret", "token"}, trimFields, "trim fields edited")
+	assert.Equal(t, []string{"details"}, extractFields, "extract fields replaced")
+}
+
+// runLogfmt runs the command on the input as it runs when there is no
+// configuration file, with the args given, and returns what it writes.
+func runLogfmt(t *testing.T, input string, args ...string) string {
+	t.Helper()
+
+	origConfig, origCmd := config, cmd
+	origWords, origWorries := WorryWords, worries
+	t.Cleanup(func() {
+		// Defining the flags again resets the options bound to them
+		newCommand(origConfig)
+		config, cmd = origConfig, origCmd
+		WorryWords, worries = origWords, origWorries
+		_ = setupLevels(origConfig)
+		_ = setupRenderRules(origConfig)
+	})
+
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv("HOME", dir)
+
+	var err error
+	config, err = LoadConfig()
+	require.NoError(t, err)
+	cmd = newCommand(config)
+
+	in := filepath.Join(dir, "in.log")
+	out := filepath.Join(dir, "out.log")
+	require.NoError(t, os.WriteFile(in, []byte(input), 0644))
+
+	cmd.SetArgs(append([]string{"--output", out, in}, args...))
+	require.NoError(t, cmd.Execute())
+
+	written, err := os.ReadFile(out)
+	require.NoError(t, err)
+	return string(written)
+}
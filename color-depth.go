@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	gc "image/color"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ColorDepth is how many colors the terminal can show. The zero value is
+// truecolor, so a RawColorizer emits 24-bit color unless told otherwise.
+type ColorDepth int
+
+const (
+	DepthTrueColor ColorDepth = iota
+	Depth256
+	Depth16
+)
+
+// colorDepths are the accepted --color-depth values. An empty value means the
+// depth was never set, which behaves as auto.
+var colorDepths = []string{"auto", "16", "256", "truecolor"}
+
+// checkColorDepth rejects an unrecognized --color-depth value.
+func checkColorDepth() error {
+	switch colorDepth {
+	case "", "auto", "16", "256", "truecolor", "24bit":
+		return nil
+	}
+	return fmt.Errorf("invalid --color-depth %q: expected one of %s", colorDepth, strings.Join(colorDepths, ", "))
+}
+
+// setupColorDepth picks the color depth from --color-depth, detecting it from
+// the environment in auto mode.
+func setupColorDepth() ColorDepth {
+	switch colorDepth {
+	case "16":
+		return Depth16
+	case "256":
+		return Depth256
+	case "truecolor", "24bit":
+		return DepthTrueColor
+	}
+	return detectColorDepth(os.Getenv)
+}
+
+// detectColorDepth works out the color depth of the terminal from the
+// environment: FORCE_COLOR, when set to a level of 1 (16 colors), 2 (256), or 3
+// (truecolor), then COLORTERM, which truecolor terminals set to "truecolor" or
+// "24bit", then the max_colors capability in the terminfo entry for TERM, then
+// the name in TERM itself. Anything unknown gets the 16 colors every color
+// terminal has.
+func detectColorDepth(getenv func(string) string) ColorDepth {
+	switch getenv("FORCE_COLOR") {
+	case "1":
+		return Depth16
+	case "2":
+		return Depth256
+	case "3":
+		return DepthTrueColor
+	}
+
+	switch strings.ToLower(getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return DepthTrueColor
+	}
+
+	term := getenv("TERM")
+	if strings.HasSuffix(term, "-direct") {
+		return DepthTrueColor
+	}
+
+	if colors := terminfoColors(getenv, term); colors > 0 {
+		switch {
+		case colors >= 1<<24:
+			return DepthTrueColor
+		case colors >= 256:
+			return Depth256
+		}
+		return Depth16
+	}
+
+	if strings.Contains(term, "256color") {
+		return Depth256
+	}
+	return Depth16
+}
+
+// terminfoMaxColors is the index of the max_colors number in a terminfo entry.
+const terminfoMaxColors = 13
+
+// terminfoColors reads max_colors from the compiled terminfo entry for term, or
+// returns 0 if there is no entry or it cannot be read.
+func terminfoColors(getenv func(string) string, term string) int {
+	if term == "" || strings.ContainsAny(term, `/\`) {
+		return 0
+	}
+
+	dirs := []string{getenv("TERMINFO")}
+	if home := getenv("HOME"); home != "" {
+		dirs = append(dirs, filepath.Join(home, ".terminfo"))
+	}
+	dirs = append(dirs, filepath.SplitList(getenv("TERMINFO_DIRS"))...)
+	dirs = append(dirs, "/etc/terminfo", "/lib/terminfo", "/usr/share/terminfo")
+
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+
+		for _, sub := range []string{term[:1], fmt.Sprintf("%x", term[0])} {
+			bs, err := os.ReadFile(filepath.Join(dir, sub, term))
+			if err == nil {
+				return parseTerminfoColors(bs)
+			}
+		}
+	}
+	return 0
+}
+
+// parseTerminfoColors reads max_colors from a compiled terminfo entry, in
+// either the legacy format with 16-bit numbers or the extended format with
+// 32-bit numbers. It returns 0 if the entry is malformed or has no max_colors.
+func parseTerminfoColors(bs []byte) int {
+	if len(bs) < 12 {
+		return 0
+	}
+
+	numSize := 0
+	switch binary.LittleEndian.Uint16(bs) {
+	case 0o432:
+		numSize = 2
+	case 0o1036:
+		numSize = 4
+	default:
+		return 0
+	}
+
+	namesSize := int(binary.LittleEndian.Uint16(bs[2:]))
+	boolCount := int(binary.LittleEndian.Uint16(bs[4:]))
+	numCount := int(binary.LittleEndian.Uint16(bs[6:]))
+	if numCount <= terminfoMaxColors {
+		return 0
+	}
+
+	// The numbers start on an even byte after the names and booleans.
+	off := 12 + namesSize + boolCount
+	off += off % 2
+	off += terminfoMaxColors * numSize
+	if off+numSize > len(bs) {
+		return 0
+	}
+
+	if numSize == 2 {
+		return int(int16(binary.LittleEndian.Uint16(bs[off:])))
+	}
+	return int(int32(binary.LittleEndian.Uint32(bs[off:])))
+}
+
+// ansi16 are the RGB values xterm uses for the 16 standard ANSI colors.
+var ansi16 = [16][3]uint8{
+	{0x00, 0x00, 0x00}, {0xcd, 0x00, 0x00}, {0x00, 0xcd, 0x00}, {0xcd, 0xcd, 0x00},
+	{0x00, 0x00, 0xee}, {0xcd, 0x00, 0xcd}, {0x00, 0xcd, 0xcd}, {0xe5, 0xe5, 0xe5},
+	{0x7f, 0x7f, 0x7f}, {0xff, 0x00, 0x00}, {0x00, 0xff, 0x00}, {0xff, 0xff, 0x00},
+	{0x5c, 0x5c, 0xff}, {0xff, 0x00, 0xff}, {0x00, 0xff, 0xff}, {0xff, 0xff, 0xff},
+}
+
+// cubeLevels are the levels of each channel in the 6×6×6 color cube of the
+// 256-color palette.
+var cubeLevels = [6]uint8{0x00, 0x5f, 0x87, 0xaf, 0xd7, 0xff}
+
+// nearestANSI16 returns the index of the standard ANSI color closest to c.
+func nearestANSI16(c gc.Color) int {
+	r, g, b := rgb8(c)
+
+	best, bestDist := 0, -1
+	for i, a := range ansi16 {
+		if d := colorDistance(r, g, b, a[0], a[1], a[2]); bestDist < 0 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// nearestANSI256 returns the index of the color closest to c in the 256-color
+// palette, choosing between the color cube and the grayscale ramp. The first 16
+// colors are left out, since terminals often change them.
+func nearestANSI256(c gc.Color) int {
+	r, g, b := rgb8(c)
+
+	ri, gi, bi := nearestCubeLevel(r), nearestCubeLevel(g), nearestCubeLevel(b)
+	cube := 16 + 36*ri + 6*gi + bi
+	cubeDist := colorDistance(r, g, b, cubeLevels[ri], cubeLevels[gi], cubeLevels[bi])
+
+	// The grayscale ramp runs from 0x08 to 0xee in steps of 10.
+	avg := (int(r) + int(g) + int(b)) / 3
+	gray := min(max((avg-8+5)/10, 0), 23)
+	level := uint8(8 + 10*gray)
+	grayDist := colorDistance(r, g, b, level, level, level)
+
+	if grayDist < cubeDist {
+		return 232 + gray
+	}
+	return cube
+}
+
+// nearestCubeLevel returns the index of the cube level closest to v.
+func nearestCubeLevel(v uint8) int {
+	best := 0
+	for i, l := range cubeLevels {
+		if absDiff(v, l) < absDiff(v, cubeLevels[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+// colorDistance measures how different two colors look, weighting the channels
+// by how sensitive the eye is to them ("redmean").
+func colorDistance(r1, g1, b1, r2, g2, b2 uint8) int {
+	rm := (int(r1) + int(r2)) / 2
+	dr, dg, db := int(r1)-int(r2), int(g1)-int(g2), int(b1)-int(b2)
+	return ((512+rm)*dr*dr)>>8 + 4*dg*dg + ((767-rm)*db*db)>>8
+}
+
+// rgb8 returns the 8-bit channels of c.
+func rgb8(c gc.Color) (uint8, uint8, uint8) {
+	r, g, b, _ := c.RGBA()
+	return uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)
+}
+
+// absDiff returns the absolute difference of a and b.
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
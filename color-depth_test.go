@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNearestANSI256 checks quantizing to the color cube and grayscale ramp.
+func TestNearestANSI256(t *testing.T) {
+	tests := map[string]struct {
+		r, g, b uint8
+		want    int
+	}{
+		"pure red":       {0xff, 0x00, 0x00, 196},
+		"cube exact":     {0x5f, 0x87, 0xaf, 67},
+		"near cube":      {0x14, 0xff, 0xff, 51},
+		"black":          {0x00, 0x00, 0x00, 16},
+		"white":          {0xff, 0xff, 0xff, 231},
+		"gray ramp":      {0xdd, 0xdd, 0xdd, 253},
+		"dark gray ramp": {0x76, 0x76, 0x76, 243},
+	}
+
+	for name, test := range tests {
+		assert.Equal(t, test.want, nearestANSI256(RGB(test.r, test.g, test.b)), name)
+	}
+}
+
+// TestNearestANSI16 checks quantizing to the standard ANSI colors.
+func TestNearestANSI16(t *testing.T) {
+	tests := map[string]struct {
+		r, g, b uint8
+		want    int
+	}{
+		"level-error gold": {0xff, 0xd7, 0x00, 11},
+		"level-fatal red":  {0xff, 0x00, 0x00, 9},
+		"level-info cyan":  {0x14, 0xff, 0xff, 14},
+		"normal gray":      {0xdd, 0xdd, 0xdd, 7},
+		"dark gray":        {0x76, 0x76, 0x76, 8},
+		"dark red":         {0xaa, 0x10, 0x10, 1},
+	}
+
+	for name, test := range tests {
+		assert.Equal(t, test.want, nearestANSI16(RGB(test.r, test.g, test.b)), name)
+	}
+}
+
+// TestRawColorizerDepth checks the escape sequence written at each depth.
+func TestRawColorizerDepth(t *testing.T) {
+	red := RGB(0xff, 0x00, 0x00)
+	gray := RGB(0x76, 0x76, 0x76)
+
+	assert.Equal(t, "\x1b[38;2;255;0;0mx\x1b[39m", (&RawColorizer{}).C(red, "x"), "truecolor")
+	assert.Equal(t, "\x1b[38;5;196mx\x1b[39m", (&RawColorizer{depth: Depth256}).C(red, "x"), "256 colors")
+	assert.Equal(t, "\x1b[91mx\x1b[39m", (&RawColorizer{depth: Depth16}).C(red, "x"), "bright 16 colors")
+	assert.Equal(t, "\x1b[90mx\x1b[39m", (&RawColorizer{depth: Depth16}).C(gray, "x"), "bright black")
+	assert.Equal(t, "\x1b[31mx\x1b[39m", (&RawColorizer{depth: Depth16}).C(RGB(0xaa, 0x10, 0x10), "x"), "normal 16 colors")
+}
+
+// writeTerminfo writes a compiled terminfo entry with the given max_colors.
+func writeTerminfo(t *testing.T, dir, term string, magic uint16, colors int) {
+	numSize := 2
+	if magic == 0o1036 {
+		numSize = 4
+	}
+
+	names := []byte(term + "|test terminal\x00")
+	bools := []byte{1, 0, 1}
+	nums := make([]byte, 15*numSize)
+	for i := 0; i < 15; i++ {
+		if numSize == 2 {
+			binary.LittleEndian.PutUint16(nums[i*2:], 0xffff)
+		} else {
+			binary.LittleEndian.PutUint32(nums[i*4:], 0xffffffff)
+		}
+	}
+	if numSize == 2 {
+		binary.LittleEndian.PutUint16(nums[terminfoMaxColors*2:], uint16(colors))
+	} else {
+		binary.LittleEndian.PutUint32(nums[terminfoMaxColors*4:], uint32(colors))
+	}
+
+	header := make([]byte, 12)
+	binary.LittleEndian.PutUint16(header, magic)
+	binary.LittleEndian.PutUint16(header[2:], uint16(len(names)))
+	binary.LittleEndian.PutUint16(header[4:], uint16(len(bools)))
+	binary.LittleEndian.PutUint16(header[6:], 15)
+
+	entry := append(append(append(header, names...), bools...), 0)
+	if (12+len(names)+len(bools))%2 == 0 {
+		entry = entry[:len(entry)-1]
+	}
+	entry = append(entry, nums...)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, term[:1]), 0o755), "make terminfo dir")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, term[:1], term), entry, 0o644), "write terminfo entry")
+}
+
+// TestDetectColorDepth covers the environment variables and the terminfo
+// lookup, in their order of precedence.
+func TestDetectColorDepth(t *testing.T) {
+	dir := t.TempDir()
+	writeTerminfo(t, dir, "legacy-256", 0o432, 256)
+	writeTerminfo(t, dir, "legacy-8", 0o432, 8)
+	writeTerminfo(t, dir, "extended-direct", 0o1036, 1<<24)
+
+	tests := []struct {
+		env  map[string]string
+		want ColorDepth
+	}{
+		{map[string]string{"COLORTERM": "truecolor", "TERM": "xterm"}, DepthTrueColor},
+		{map[string]string{"COLORTERM": "24bit"}, DepthTrueColor},
+		{map[string]string{"TERM": "xterm-direct"}, DepthTrueColor},
+		{map[string]string{"TERMINFO": dir, "TERM": "legacy-256"}, Depth256},
+		{map[string]string{"TERMINFO": dir, "TERM": "legacy-8"}, Depth16},
+		{map[string]string{"TERMINFO": dir, "TERM": "extended-direct"}, DepthTrueColor},
+		{map[string]string{"TERMINFO": dir, "TERM": "screen-256color"}, Depth256},
+		{map[string]string{"TERMINFO": dir, "TERM": "vt100"}, Depth16},
+		{map[string]string{"FORCE_COLOR": "1", "COLORTERM": "truecolor"}, Depth16},
+		{map[string]string{"FORCE_COLOR": "2", "TERM": "vt100"}, Depth256},
+		{map[string]string{"FORCE_COLOR": "3", "TERM": "vt100"}, DepthTrueColor},
+		{map[string]string{"FORCE_COLOR": "true", "TERM": "xterm-256color"}, Depth256},
+		{map[string]string{}, Depth16},
+	}
+
+	for _, test := range tests {
+		getenv := func(k string) string {
+			if k == "TERMINFO_DIRS" || k == "HOME" {
+				return dir
+			}
+			return test.env[k]
+		}
+
+		assert.Equal(t, test.want, detectColorDepth(getenv), "env %v", test.env)
+	}
+}
+
+// TestCheckColorDepth covers the accepted --color-depth values.
+func TestCheckColorDepth(t *testing.T) {
+	orig := colorDepth
+	t.Cleanup(func() { colorDepth = orig })
+
+	for _, depth := range []string{"", "auto", "16", "256", "truecolor", "24bit"} {
+		colorDepth = depth
+		assert.NoError(t, checkColorDepth(), "%q is valid", depth)
+	}
+
+	colorDepth = "88"
+	assert.ErrorContains(t, checkColorDepth(), "auto, 16, 256, truecolor", "unknown depth rejected")
+}
@@ -7,7 +7,6 @@ import (
 	"os"
 	"strings"
 
-	"github.com/wayneashleyberry/truecolor/pkg/color"
 	"golang.org/x/sys/unix"
 )
 
@@ -25,38 +24,98 @@ const (
 	ColorMessage       ColorName = "message"
 	ColorStackTrace    ColorName = "stacktrace"
 	ColorData          ColorName = "data"
-	ColorDataLiteral   ColorName = "data-literal"
+	ColorDataKey       ColorName = "data-key"
+	ColorDataString    ColorName = "data-string"
+	ColorDataNumber    ColorName = "data-number"
+	ColorDataBool      ColorName = "data-bool"
+	ColorDataNull      ColorName = "data-null"
+	ColorDataPunct     ColorName = "data-punct"
 	ColorWorryInfo     ColorName = "worry-info"
 	ColorWorryError    ColorName = "worry-err"
 	ColorWorryWarn     ColorName = "worry-warn"
 	ColorWorryCritical ColorName = "worry-crit"
 	ColorExtracted     ColorName = "extracted"
+	ColorLogger        ColorName = "logger"
+	ColorCaller        ColorName = "caller"
+
+	// ColorDataLiteral is not in the palette. It was the color of strings,
+	// numbers, booleans, and nulls in the trailing fields before each had its
+	// own, and a configured data-literal still sets those left unset. See
+	// DataLiteralColors.
+	ColorDataLiteral ColorName = "data-literal"
 )
 
+// DataLiteralColors are the colors that replaced ColorDataLiteral.
+var DataLiteralColors = []ColorName{ColorDataString, ColorDataNumber, ColorDataBool, ColorDataNull}
+
+// IdentityColors are the colors values of identity fields are shown in. Each
+// value is given one of these by hashing it, so the same ID always has the
+// same color. They are spaced around the color wheel to tell them apart.
+var IdentityColors = []ColorName{
+	"identity-0", "identity-1", "identity-2", "identity-3",
+	"identity-4", "identity-5", "identity-6", "identity-7",
+	"identity-8", "identity-9", "identity-10", "identity-11",
+}
+
 func RGB(r, g, b uint8) gc.Color {
 	return &gc.NRGBA{R: r, G: g, B: b, A: 255}
 }
 
-type Palette map[ColorName]gc.Color
+// Style is how a palette color is shown: a foreground and background color,
+// either of which may be nil to leave the terminal's own, and text attributes.
+type Style struct {
+	FG, BG    gc.Color
+	Bold      bool
+	Dim       bool
+	Italic    bool
+	Underline bool
+	Reverse   bool
+}
+
+// Fg returns a style with only a foreground color.
+func Fg(r, g, b uint8) Style {
+	return Style{FG: RGB(r, g, b)}
+}
+
+type Palette map[ColorName]Style
 
 var DefaultPalette = Palette{
-	ColorNormal:        RGB(0xdd, 0xdd, 0xdd),
-	ColorDateTime:      RGB(0xdd, 0xdd, 0xdd),
-	ColorLevelDebug:    RGB(0x66, 0x66, 0xff),
-	ColorLevelInfo:     RGB(0x14, 0xff, 0xff),
-	ColorLevelWarn:     RGB(0xff, 0xff, 0x00),
-	ColorLevelError:    RGB(0xff, 0xd7, 0x00),
-	ColorLevelDPanic:   RGB(0xff, 0x5f, 0x00),
-	ColorLevelFatal:    RGB(0xff, 0x00, 0x00),
-	ColorMessage:       RGB(0xff, 0xff, 0xff),
-	ColorStackTrace:    RGB(0x76, 0x76, 0x76),
-	ColorData:          RGB(0xaa, 0xaa, 0xaa),
-	ColorDataLiteral:   RGB(0x88, 0x88, 0x99),
-	ColorWorryInfo:     RGB(0x66, 0x66, 0xff),
-	ColorWorryError:    RGB(0xff, 0xd7, 0x00),
-	ColorWorryWarn:     RGB(0xff, 0xff, 0x00),
-	ColorWorryCritical: RGB(0xff, 0x5f, 0x00),
-	ColorExtracted:     RGB(0x44, 0xaa, 0xaa),
+	ColorNormal:        Fg(0xdd, 0xdd, 0xdd),
+	ColorDateTime:      Fg(0xdd, 0xdd, 0xdd),
+	ColorLevelDebug:    Fg(0x66, 0x66, 0xff),
+	ColorLevelInfo:     Fg(0x14, 0xff, 0xff),
+	ColorLevelWarn:     Fg(0xff, 0xff, 0x00),
+	ColorLevelError:    Fg(0xff, 0xd7, 0x00),
+	ColorLevelDPanic:   Fg(0xff, 0x5f, 0x00),
+	ColorLevelFatal:    Fg(0xff, 0x00, 0x00),
+	ColorMessage:       Fg(0xff, 0xff, 0xff),
+	ColorStackTrace:    Fg(0x76, 0x76, 0x76),
+	ColorData:          Fg(0xaa, 0xaa, 0xaa),
+	ColorDataKey:       Fg(0x87, 0xaf, 0xd7),
+	ColorDataString:    Fg(0x88, 0xaa, 0x88),
+	ColorDataNumber:    Fg(0xd7, 0xaf, 0x87),
+	ColorDataBool:      Fg(0xaf, 0x87, 0xd7),
+	ColorDataNull:      Fg(0x76, 0x76, 0x76),
+	ColorDataPunct:     Fg(0xaa, 0xaa, 0xaa),
+	ColorWorryInfo:     Fg(0x66, 0x66, 0xff),
+	ColorWorryError:    Fg(0xff, 0xd7, 0x00),
+	ColorWorryWarn:     Fg(0xff, 0xff, 0x00),
+	ColorWorryCritical: Fg(0xff, 0x5f, 0x00),
+	ColorExtracted:     Fg(0x44, 0xaa, 0xaa),
+	ColorLogger:        Fg(0x88, 0x88, 0xcc),
+	ColorCaller:        Fg(0x76, 0x76, 0x76),
+	IdentityColors[0]:  Fg(0xff, 0x87, 0x87),
+	IdentityColors[1]:  Fg(0x5f, 0xff, 0xff),
+	IdentityColors[2]:  Fg(0xff, 0xd7, 0x5f),
+	IdentityColors[3]:  Fg(0x87, 0x87, 0xff),
+	IdentityColors[4]:  Fg(0x87, 0xff, 0x87),
+	IdentityColors[5]:  Fg(0xff, 0x87, 0xff),
+	IdentityColors[6]:  Fg(0xff, 0xaf, 0x5f),
+	IdentityColors[7]:  Fg(0x5f, 0xaf, 0xff),
+	IdentityColors[8]:  Fg(0xd7, 0xff, 0x5f),
+	IdentityColors[9]:  Fg(0xaf, 0x87, 0xff),
+	IdentityColors[10]: Fg(0x5f, 0xff, 0xaf),
+	IdentityColors[11]: Fg(0xff, 0x87, 0xaf),
 }
 
 type PlainColorizer interface {
@@ -75,11 +134,72 @@ func (fc *SugaredColorizer) Cf(color ColorName, f string, args ...any) string {
 	return fc.C(color, fmt.Sprintf(f, args...))
 }
 
-type RawColorizer struct{}
+// RawColorizer colors text with any color, quantized to the nearest color the
+// terminal can show when it has fewer than 24-bit colors.
+type RawColorizer struct {
+	depth ColorDepth
+}
 
 func (rc *RawColorizer) C(c gc.Color, v ...any) string {
-	r, g, b, a := c.RGBA()
-	return color.RGBA(gc.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: uint8(a >> 8)}).Sprint(v...)
+	return rc.S(Style{FG: c}, v...)
+}
+
+// S shows text in a style, turning on its colors and attributes before the text
+// and off again after it.
+func (rc *RawColorizer) S(s Style, v ...any) string {
+	var on, off []string
+	if s.FG != nil {
+		on = append(on, rc.color(s.FG, 38))
+		off = append(off, "\x1b[39m")
+	}
+	if s.BG != nil {
+		on = append(on, rc.color(s.BG, 48))
+		off = append(off, "\x1b[49m")
+	}
+	if s.Bold {
+		on = append(on, "\x1b[1m")
+	}
+	if s.Dim {
+		on = append(on, "\x1b[2m")
+	}
+	if s.Bold || s.Dim {
+		// One code turns off both bold and dim.
+		off = append(off, "\x1b[22m")
+	}
+	if s.Italic {
+		on = append(on, "\x1b[3m")
+		off = append(off, "\x1b[23m")
+	}
+	if s.Underline {
+		on = append(on, "\x1b[4m")
+		off = append(off, "\x1b[24m")
+	}
+	if s.Reverse {
+		on = append(on, "\x1b[7m")
+		off = append(off, "\x1b[27m")
+	}
+
+	return strings.Join(on, "") + fmt.Sprint(v...) + strings.Join(off, "")
+}
+
+// color returns the escape sequence that sets the foreground (base 38) or the
+// background (base 48) to c, quantized to the color depth.
+func (rc *RawColorizer) color(c gc.Color, base int) string {
+	switch rc.depth {
+	case Depth256:
+		return fmt.Sprintf("\x1b[%d;5;%dm", base, nearestANSI256(c))
+	case Depth16:
+		// The standard colors are 30-37 and the bright ones 90-97 for the
+		// foreground, and ten more for the background.
+		code := base - 8 + nearestANSI16(c)
+		if code >= base {
+			code += 60 - 8
+		}
+		return fmt.Sprintf("\x1b[%dm", code)
+	}
+
+	r, g, b := rgb8(c)
+	return fmt.Sprintf("\x1b[%d;2;%d;%d;%dm", base, r, g, b)
 }
 
 // isTerminal reports whether w writes to a terminal. Anything that is not an
@@ -96,21 +216,74 @@ func isTerminal(w io.Writer) bool {
 	return err == nil
 }
 
+// terminalWidth reports the number of columns of the terminal w writes to, or 0
+// if w is not a terminal.
+func terminalWidth(w io.Writer) int {
+	f, ok := w.(*os.File)
+	if !ok {
+		return 0
+	}
+
+	ws, err := unix.IoctlGetWinsize(int(f.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0
+	}
+	return int(ws.Col)
+}
+
 type ColorAuto struct {
 	on  *ColorOn
 	off *ColorOff
 	tty bool
 }
 
-// NewColorAuto builds a colorizer that emits color only when out is a terminal.
-func NewColorAuto(p Palette, out io.Writer) *ColorAuto {
+// NewColorAuto builds a colorizer that emits color only when out is a terminal,
+// unless the environment says otherwise. See autoColor.
+func NewColorAuto(p Palette, depth ColorDepth, out io.Writer) *ColorAuto {
 	return &ColorAuto{
-		on:  NewColorOn(p),
+		on:  NewColorOn(p, depth),
 		off: &ColorOff{},
-		tty: isTerminal(out),
+		tty: autoColor(os.Getenv, out),
 	}
 }
 
+// autoColor decides whether auto mode emits color, following the common
+// conventions for turning color on and off through the environment, in this
+// order:
+//
+//  1. NO_COLOR set to anything but "" turns color off.
+//  2. FORCE_COLOR set to "0" or "false" turns color off, and set to anything
+//     else but "" turns it on.
+//  3. CLICOLOR_FORCE set to anything but "" or "0" turns color on.
+//  4. CLICOLOR set to "0" turns color off.
+//  5. Otherwise, color is on only when out is a terminal.
+//
+// These only apply in auto mode. Setting --color, LOGFMT_COLORIZE, or colorize
+// in the configuration to on or off overrides all of them.
+func autoColor(getenv func(string) string, out io.Writer) bool {
+	if getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	switch force := getenv("FORCE_COLOR"); strings.ToLower(force) {
+	case "":
+	case "0", "false":
+		return false
+	default:
+		return true
+	}
+
+	if force := getenv("CLICOLOR_FORCE"); force != "" && force != "0" {
+		return true
+	}
+
+	if getenv("CLICOLOR") == "0" {
+		return false
+	}
+
+	return isTerminal(out)
+}
+
 func (ca *ColorAuto) C(c ColorName, v ...any) string {
 	if ca.tty {
 		return ca.on.C(c, v...)
@@ -129,34 +302,28 @@ type ColorOn struct {
 	palette Palette
 }
 
-func NewColorOn(p Palette) *ColorOn {
-	return &ColorOn{palette: p}
+// NewColorOn builds a colorizer that always emits color, with as many colors
+// as depth allows.
+func NewColorOn(p Palette, depth ColorDepth) *ColorOn {
+	return &ColorOn{raw: RawColorizer{depth: depth}, palette: p}
 }
 
 func (co *ColorOn) C(c ColorName, v ...any) string {
-	gcc, ok := co.palette[c]
+	style, ok := co.palette[c]
 	if !ok {
-		gcc, ok = co.palette[ColorNormal]
+		style, ok = co.palette[ColorNormal]
 		if !ok {
-			gcc = RGB(0xdd, 0xdd, 0xdd)
+			style = Fg(0xdd, 0xdd, 0xdd)
 		}
 	}
-	return co.raw.C(gcc, v...)
-}
-
-var l2cn = map[string]ColorName{
-	"debug":  ColorLevelDebug,
-	"info":   ColorLevelInfo,
-	"warn":   ColorLevelWarn,
-	"error":  ColorLevelError,
-	"dpanic": ColorLevelDPanic,
-	"fatal":  ColorLevelFatal,
+	return co.raw.S(style, v...)
 }
 
+// LevelToColorName returns the palette color of the level, or the info color
+// for unknown levels.
 func LevelToColorName(level string) ColorName {
-	cn, ok := l2cn[strings.ToLower(level)]
-	if ok {
-		return cn
+	if l, ok := lookupLevel(level); ok {
+		return l.Color
 	}
-	return "level-info"
+	return ColorLevelInfo
 }
@@ -64,7 +64,7 @@ func TestColorAutoOffWhenNotATerminal(t *testing.T) {
 	require.NoError(t, err, "create regular file")
 	defer func() { _ = regular.Close() }()
 
-	ca := NewColorAuto(DefaultPalette, regular)
+	ca := NewColorAuto(DefaultPalette, DepthTrueColor, regular)
 	require.False(t, ca.tty, "auto mode detects non-terminal")
 
 	got := ca.C(ColorLevelError, "boom")
@@ -76,7 +76,7 @@ func TestColorAutoOffWhenNotATerminal(t *testing.T) {
 // is on" half of auto mode is never executed and detection that always returns
 // false would pass the suite.
 func TestColorAutoOnWhenTerminal(t *testing.T) {
-	ca := &ColorAuto{on: NewColorOn(DefaultPalette), off: &ColorOff{}, tty: true}
+	ca := &ColorAuto{on: NewColorOn(DefaultPalette, DepthTrueColor), off: &ColorOff{}, tty: true}
 
 	got := ca.C(ColorLevelError, "boom")
 
@@ -89,9 +89,9 @@ func TestColorAutoOnWhenTerminal(t *testing.T) {
 // escape rather than the stored palette, so it fails if the palette is kept but
 // never consulted.
 func TestColorAutoUsesSuppliedPalette(t *testing.T) {
-	custom := Palette{ColorLevelError: RGB(0x01, 0x02, 0x03)}
+	custom := Palette{ColorLevelError: Fg(0x01, 0x02, 0x03)}
 
-	ca := &ColorAuto{on: NewColorOn(custom), off: &ColorOff{}, tty: true}
+	ca := &ColorAuto{on: NewColorOn(custom, DepthTrueColor), off: &ColorOff{}, tty: true}
 
 	assert.Contains(t, ca.C(ColorLevelError, "boom"), "38;2;1;2;3", "custom color used, not the default palette")
 }
@@ -99,10 +99,87 @@ func TestColorAutoUsesSuppliedPalette(t *testing.T) {
 // TestNewColorAutoWiring checks that NewColorAuto passes the palette and the
 // detection result through, since the tests above build ColorAuto directly.
 func TestNewColorAutoWiring(t *testing.T) {
-	custom := Palette{ColorLevelError: RGB(0x01, 0x02, 0x03)}
+	custom := Palette{ColorLevelError: Fg(0x01, 0x02, 0x03)}
 
-	ca := NewColorAuto(custom, &bytes.Buffer{})
+	ca := NewColorAuto(custom, DepthTrueColor, &bytes.Buffer{})
 
 	assert.Equal(t, custom, ca.on.palette, "supplied palette reaches the on colorizer")
 	assert.False(t, ca.tty, "buffer is not a terminal")
 }
+
+// TestRawColorizerStyle checks the escape sequences for backgrounds and text
+// attributes at each color depth, and that each is turned off after the text.
+func TestRawColorizerStyle(t *testing.T) {
+	fatal := Style{FG: RGB(0xff, 0xff, 0xff), BG: RGB(0xff, 0x00, 0x00), Bold: true}
+
+	assert.Equal(t, "\x1b[38;2;255;255;255m\x1b[48;2;255;0;0m\x1b[1mx\x1b[39m\x1b[49m\x1b[22m", (&RawColorizer{}).S(fatal, "x"), "truecolor")
+	assert.Equal(t, "\x1b[38;5;231m\x1b[48;5;196m\x1b[1mx\x1b[39m\x1b[49m\x1b[22m", (&RawColorizer{depth: Depth256}).S(fatal, "x"), "256 colors")
+	assert.Equal(t, "\x1b[97m\x1b[101m\x1b[1mx\x1b[39m\x1b[49m\x1b[22m", (&RawColorizer{depth: Depth16}).S(fatal, "x"), "16 colors")
+	assert.Equal(t, "\x1b[41mx\x1b[49m", (&RawColorizer{depth: Depth16}).S(Style{BG: RGB(0xaa, 0x10, 0x10)}, "x"), "normal 16 color background")
+
+	all := Style{Bold: true, Dim: true, Italic: true, Underline: true, Reverse: true}
+	assert.Equal(t, "\x1b[1m\x1b[2m\x1b[3m\x1b[4m\x1b[7mx\x1b[22m\x1b[23m\x1b[24m\x1b[27m", (&RawColorizer{}).S(all, "x"), "attributes")
+	assert.Equal(t, "x", (&RawColorizer{}).S(Style{}, "x"), "no style")
+}
+
+// TestParseStyle covers the parts of a style spec and their errors.
+func TestParseStyle(t *testing.T) {
+	tests := map[string]Style{
+		"#ff0000":                   Fg(0xff, 0x00, 0x00),
+		"rgb(255, 0, 0)":            Fg(0xff, 0x00, 0x00),
+		"bold #ffffff on #ff0000":   {FG: RGB(0xff, 0xff, 0xff), BG: RGB(0xff, 0x00, 0x00), Bold: true},
+		"on 255,0,0 Underline":      {BG: RGB(0xff, 0x00, 0x00), Underline: true},
+		"dim italic":                {Dim: true, Italic: true},
+		"reverse rgb(1,2,3)":        {FG: RGB(0x01, 0x02, 0x03), Reverse: true},
+		"":                          {},
+		"  767676   dim  on 000000": {FG: RGB(0x76, 0x76, 0x76), BG: RGB(0x00, 0x00, 0x00), Dim: true},
+	}
+	for spec, want := range tests {
+		got, err := parseStyle(spec)
+		require.NoError(t, err, "parse %q", spec)
+		assert.Equal(t, want, got, "style of %q", spec)
+	}
+
+	for _, spec := range []string{"blink", "#ff0000 #00ff00", "bold on", "on bold"} {
+		_, err := parseStyle(spec)
+		assert.Error(t, err, "%q is rejected", spec)
+	}
+}
+
+// TestGetCustomPaletteDataLiteral checks that a configured data-literal colors
+// the strings, numbers, booleans, and nulls that are not configured themselves.
+func TestGetCustomPaletteDataLiteral(t *testing.T) {
+	c := DefaultConfig()
+	c.Colors = map[string]string{
+		"data-literal": "#112233",
+		"data-number":  "#445566",
+	}
+
+	p, err := c.GetCustomPalette(DefaultPalette)
+	require.NoError(t, err)
+
+	literal := Style{FG: RGB(0x11, 0x22, 0x33)}
+	assert.Equal(t, literal, p[ColorDataString], "string")
+	assert.Equal(t, Style{FG: RGB(0x44, 0x55, 0x66)}, p[ColorDataNumber], "number keeps its own color")
+	assert.Equal(t, literal, p[ColorDataBool], "bool")
+	assert.Equal(t, literal, p[ColorDataNull], "null")
+	assert.NotContains(t, p, ColorDataLiteral, "not a palette color")
+	assert.Equal(t, "#112233", c.Colors["data-literal"], "config left as is")
+}
+
+// TestGetCustomPaletteStyle checks that a configured style with only
+// attributes keeps the theme's colors.
+func TestGetCustomPaletteStyle(t *testing.T) {
+	c := DefaultConfig()
+	c.Colors = map[string]string{
+		"level-fatal": "bold",
+		"caller":      "dim #888888",
+	}
+
+	p, err := c.GetCustomPalette(DefaultPalette)
+	require.NoError(t, err)
+
+	assert.Equal(t, Style{FG: DefaultPalette[ColorLevelFatal].FG, Bold: true}, p[ColorLevelFatal], "bold in the theme's color")
+	assert.Equal(t, Style{FG: RGB(0x88, 0x88, 0x88), Dim: true}, p[ColorCaller], "dim in a new color")
+	assert.Equal(t, DefaultPalette[ColorMessage], p[ColorMessage], "the rest of the theme")
+}
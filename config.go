@@ -3,8 +3,11 @@ package main
 import (
 	"fmt"
 	gc "image/color"
+	"maps"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/spf13/viper"
@@ -16,8 +19,21 @@ type Config struct {
 	OutputFile             string              `yaml:"output_file" mapstructure:"output_file"`
 	AppendToFile           bool                `yaml:"append_to_file" mapstructure:"append_to_file"`
 	Colorize               string              `yaml:"colorize" mapstructure:"colorize"`
+	ColorDepth             string              `yaml:"color_depth" mapstructure:"color_depth"`
+	Theme                  string              `yaml:"theme" mapstructure:"theme"`
+	OutputFormat           string              `yaml:"output_format" mapstructure:"output_format"`
+	Columns                []string            `yaml:"columns" mapstructure:"columns"`
+	Overflow               string              `yaml:"overflow" mapstructure:"overflow"`
+	Expand                 string              `yaml:"expand" mapstructure:"expand"`
+	TimeZone               string              `yaml:"time_zone" mapstructure:"time_zone"`
+	TimeFormat             string              `yaml:"time_format" mapstructure:"time_format"`
+	Relative               string              `yaml:"relative" mapstructure:"relative"`
 	HighlightWorryWords    bool                `yaml:"highlight_worry_words" mapstructure:"highlight_worry_words"`
+	WorryIn                []string            `yaml:"worry_in" mapstructure:"worry_in"`
+	EscalateWorries        bool                `yaml:"escalate_worries" mapstructure:"escalate_worries"`
+	MinLevel               string              `yaml:"min_level" mapstructure:"min_level"`
 	ExperimentalAccessLogs bool                `yaml:"experimental_access_logs" mapstructure:"experimental_access_logs"`
+	DecodeEmbedded         bool                `yaml:"decode_embedded" mapstructure:"decode_embedded"`
 	TimestampField         string              `yaml:"timestamp_field" mapstructure:"timestamp_field"`
 	MessageField           string              `yaml:"message_field" mapstructure:"message_field"`
 	LevelField             string              `yaml:"level_field" mapstructure:"level_field"`
@@ -25,8 +41,13 @@ type Config struct {
 	TrimFields             []string            `yaml:"trim_fields" mapstructure:"trim_fields"`
 	ShowNull               bool                `yaml:"show_null" mapstructure:"show_null"`
 	ExtractFields          []string            `yaml:"extract_fields" mapstructure:"extract_fields"`
+	IdentityFields         []string            `yaml:"identity_fields" mapstructure:"identity_fields"`
+	Render                 []RenderRuleConfig  `yaml:"render" mapstructure:"render"`
+	Levels                 []LevelConfig       `yaml:"levels" mapstructure:"levels"`
 	Colors                 map[string]string   `yaml:"colors" mapstructure:"colors"`
 	WorryWords             map[string][]string `yaml:"worries" mapstructure:"worries"`
+	WorriesAdd             map[string][]string `yaml:"worries_add,omitempty" mapstructure:"worries_add"`
+	WorriesRemove          []string            `yaml:"worries_remove,omitempty" mapstructure:"worries_remove"`
 }
 
 var worryWordConfigSeverity = map[string]WorrySeverity{
@@ -43,8 +64,18 @@ func DefaultConfig() *Config {
 		OutputFile:             "-",
 		AppendToFile:           false,
 		Colorize:               "auto",
+		ColorDepth:             "auto",
+		Theme:                  "dark",
+		OutputFormat:           "console",
+		Columns:                []string{"ts", "level", "msg"},
+		Overflow:               "wrap",
+		Expand:                 "inline",
+		Relative:               "off",
 		HighlightWorryWords:    true,
+		WorryIn:                []string{"message", "extracted", "data", "raw"},
+		EscalateWorries:        false,
 		ExperimentalAccessLogs: false,
+		DecodeEmbedded:         false,
 		TimestampField:         "ts",
 		MessageField:           "msg",
 		LevelField:             "level",
@@ -52,12 +83,13 @@ func DefaultConfig() *Config {
 		TrimFields:             []string{"level", "msg", "stacktrace", "error"},
 		ShowNull:               false,
 		ExtractFields:          []string{"error", "stacktrace"},
+		IdentityFields:         []string{},
 		Colors:                 make(map[string]string),
 	}
 
 	c.WorryWords = make(map[string][]string, len(worryWordConfigSeverity))
 	for csev, wsev := range worryWordConfigSeverity {
-		c.WorryWords[csev] = invertWorryWords[wsev]
+		c.WorryWords[csev] = defaultWorriesOf(wsev)
 	}
 
 	return c
@@ -97,9 +129,47 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	// Field lists written with + and - adjust the defaults
+	defaults := DefaultConfig()
+	config.TrimFields = mergeFields(defaults.TrimFields, config.TrimFields)
+	config.ExtractFields = mergeFields(defaults.ExtractFields, config.ExtractFields)
+
 	return config, nil
 }
 
+// mergeFields applies a list of field names to base. A list of plain names
+// replaces base. Names prefixed with + are added and names prefixed with - are
+// removed, starting from the plain names in the list, or from base if there are
+// none, so "+password" adds to base and "-error" removes from it.
+func mergeFields(base, fields []string) []string {
+	var plain, edits []string
+	for _, field := range fields {
+		if strings.HasPrefix(field, "+") || strings.HasPrefix(field, "-") {
+			edits = append(edits, field)
+			continue
+		}
+		plain = append(plain, field)
+	}
+
+	if len(edits) == 0 {
+		return fields
+	}
+
+	merged := slices.Clone(base)
+	if len(plain) > 0 {
+		merged = plain
+	}
+
+	for _, edit := range edits {
+		field := edit[1:]
+		merged = slices.DeleteFunc(merged, func(f string) bool { return f == field })
+		if edit[0] == '+' {
+			merged = append(merged, field)
+		}
+	}
+	return merged
+}
+
 // addConfigPaths adds configuration file search paths:
 // 1. Current directory and upward search
 // 2. Home directory
@@ -136,8 +206,21 @@ func setViperDefaults(v *viper.Viper, config *Config) {
 	v.SetDefault("output_file", config.OutputFile)
 	v.SetDefault("append_to_file", config.AppendToFile)
 	v.SetDefault("colorize", config.Colorize)
+	v.SetDefault("color_depth", config.ColorDepth)
+	v.SetDefault("theme", config.Theme)
+	v.SetDefault("output_format", config.OutputFormat)
+	v.SetDefault("columns", config.Columns)
+	v.SetDefault("overflow", config.Overflow)
+	v.SetDefault("expand", config.Expand)
+	v.SetDefault("time_zone", config.TimeZone)
+	v.SetDefault("time_format", config.TimeFormat)
+	v.SetDefault("relative", config.Relative)
 	v.SetDefault("highlight_worry_words", config.HighlightWorryWords)
+	v.SetDefault("worry_in", config.WorryIn)
+	v.SetDefault("escalate_worries", config.EscalateWorries)
+	v.SetDefault("min_level", config.MinLevel)
 	v.SetDefault("experimental_access_logs", config.ExperimentalAccessLogs)
+	v.SetDefault("decode_embedded", config.DecodeEmbedded)
 	v.SetDefault("timestamp_field", config.TimestampField)
 	v.SetDefault("message_field", config.MessageField)
 	v.SetDefault("level_field", config.LevelField)
@@ -145,31 +228,105 @@ func setViperDefaults(v *viper.Viper, config *Config) {
 	v.SetDefault("trim_fields", config.TrimFields)
 	v.SetDefault("show_null", config.ShowNull)
 	v.SetDefault("extract_fields", config.ExtractFields)
+	v.SetDefault("identity_fields", config.IdentityFields)
+	v.SetDefault("render", config.Render)
+	v.SetDefault("levels", config.Levels)
 	v.SetDefault("colors", config.Colors)
 	v.SetDefault("worry_words", config.WorryWords)
+	v.SetDefault("worries_add", config.WorriesAdd)
+	v.SetDefault("worries_remove", config.WorriesRemove)
 }
 
-// GetCustomPalette creates a color palette from the configuration
-func (c *Config) GetCustomPalette() (Palette, error) {
+// GetCustomPalette creates a color palette from the configuration, layering
+// the configured colors on top of the theme
+func (c *Config) GetCustomPalette(theme Palette) (Palette, error) {
 	palette := make(Palette)
 
-	// Start with default palette
-	for name, color := range DefaultPalette {
+	// Start with the theme
+	for name, color := range theme {
 		palette[name] = color
 	}
 
-	// Override with custom colors from config
-	for colorName, colorValue := range c.Colors {
-		color, err := parseColor(colorValue)
+	// A data-literal from before strings, numbers, booleans, and nulls had
+	// their own colors stands in for those of them not configured.
+	colors := c.Colors
+	if literal, ok := colors[string(ColorDataLiteral)]; ok {
+		colors = maps.Clone(colors)
+		delete(colors, string(ColorDataLiteral))
+		for _, name := range DataLiteralColors {
+			if _, ok := colors[string(name)]; !ok {
+				colors[string(name)] = literal
+			}
+		}
+	}
+
+	// Override with custom colors from config, keeping the theme's colors
+	// where a style only gives attributes
+	for colorName, colorValue := range colors {
+		style, err := parseStyle(colorValue)
 		if err != nil {
 			return nil, fmt.Errorf("invalid color %q for %q: %w", colorValue, colorName, err)
 		}
-		palette[ColorName(colorName)] = color
+
+		theme := palette[ColorName(colorName)]
+		if style.FG == nil {
+			style.FG = theme.FG
+		}
+		if style.BG == nil {
+			style.BG = theme.BG
+		}
+		palette[ColorName(colorName)] = style
 	}
 
 	return palette, nil
 }
 
+// styleWord matches the words of a style, keeping "rgb(255, 0, 0)" whole.
+var styleWord = regexp.MustCompile(`rgb\([^)]*\)|\S+`)
+
+// parseStyle parses a style: a foreground color, "on" and a background color,
+// and any of the attributes bold, dim, italic, underline, and reverse, in any
+// order, like "bold #ffffff on #ff0000". Every part is optional.
+func parseStyle(styleStr string) (Style, error) {
+	var style Style
+	words := styleWord.FindAllString(styleStr, -1)
+	for i := 0; i < len(words); i++ {
+		switch word := strings.ToLower(words[i]); word {
+		case "bold":
+			style.Bold = true
+		case "dim":
+			style.Dim = true
+		case "italic":
+			style.Italic = true
+		case "underline":
+			style.Underline = true
+		case "reverse":
+			style.Reverse = true
+		case "on":
+			if i+1 == len(words) {
+				return Style{}, fmt.Errorf("missing background color after %q", word)
+			}
+			i++
+			bg, err := parseColor(words[i])
+			if err != nil {
+				return Style{}, err
+			}
+			style.BG = bg
+		default:
+			if style.FG != nil {
+				return Style{}, fmt.Errorf("unexpected %q after the foreground color", words[i])
+			}
+			fg, err := parseColor(words[i])
+			if err != nil {
+				return Style{}, err
+			}
+			style.FG = fg
+		}
+	}
+
+	return style, nil
+}
+
 // parseColor parses a color string in various formats:
 // - hex: "#ff0000" or "ff0000"
 // - rgb: "rgb(255,0,0)" or "255,0,0"
@@ -225,11 +382,29 @@ CONFIGURATION OPTIONS:
 Output Options:
   output_file: "-"              # Output file ("-" for stdout)  
   append_to_file: false         # Append to output file
-  colorize: "auto"              # Color mode: "auto" (color only on a terminal), "on", "off"
+  colorize: "auto"              # Color mode: "auto" (color only on a terminal, unless NO_COLOR,
+                                # FORCE_COLOR, CLICOLOR, or CLICOLOR_FORCE say otherwise), "on", "off"
+  color_depth: "auto"           # Colors to use: "auto" (detected), "16", "256", "truecolor"
+  theme: "dark"                 # Palette: "dark", "light", "solarized-dark", "solarized-light",
+                                # "high-contrast", "colorblind", or "auto" (detect the background)
+  output_format: "console"      # Output format: "console", "aligned", "logfmt", "json", "csv", "tsv", or "html"
+  overflow: "wrap"              # Long lines in aligned output: "wrap" or "truncate"
+  expand: "inline"              # Trailing fields: "inline", "tree", or a length beyond which to use a tree
+  time_zone: ""                 # Show timestamps in "local", "UTC", or a zone like "America/Chicago" ("" as logged)
+  time_format: ""               # Go layout, strftime pattern, or preset: "rfc3339", "rfc3339nano",
+                                # "short", "time-only", "kitchen", "stamp" ("" is rfc3339nano)
+  relative: "off"               # Show time since the "first" line or the "prev" line instead
 
 Processing Options:
   highlight_worry_words: true   # Highlight error/warning keywords
+  worry_in: [message, extracted, data, raw]
+                                # Where to highlight them: the message, extracted fields,
+                                # trailing field values, and lines that are not log entries
+  escalate_worries: false       # Raise entries with an err or crit worry word in the
+                                # message to error or fatal, shown as ~ERROR or ~FATAL
+  min_level: ""                 # Hide entries less severe than this level, like warn
   experimental_access_logs: false  # Enable access log parsing
+  decode_embedded: false        # Decode JSON embedded in strings and messages
   show_null: false              # Show null values in output
 
 Field Configuration:
@@ -249,10 +424,46 @@ Field Arrays:
     - "error"
     - "stacktrace"
 
-Custom Worry Words:             # Words that get highlighted
+  trim_fields: [+password, -error]
+                                # +field and -field add to and remove from the
+                                # defaults, instead of replacing them
+
+  identity_fields:              # Fields whose values are colored by a hash of
+    - "request_id"              # the value, so the same ID is always the same
+    - "trace_id"                # color (none by default)
+
+  columns:                      # Fields written as columns in csv and tsv output
+    - "ts"                      # ts, level, and msg name the timestamp, level,
+    - "level"                   # and message, whatever their field names
+    - "msg"
+
+Render Rules:                   # Format field values to be easier to read
+  render:
+    - field: duration_ms        # Field name, or a pattern like "*_ms"
+      as: duration              # "duration", "bytes", "percent", or "hash"
+      unit: ms                  # Unit of the values (duration: ns, us, ms, s, m, h;
+                                # bytes: B, KB, MB, KiB, MiB, ...; percent: ratio, percent)
+      thresholds:               # Color the value when it is above a limit
+        - above: 1s
+          color: worry-warn
+    - field: commit
+      as: hash
+      length: 8                 # Characters of a hash to keep
+
+Custom Levels:                  # Levels beyond trace, debug, info, warn, error, dpanic, panic, fatal
+  levels:
+    - name: audit               # Canonical name
+      aliases: [aud]            # Other names for the level
+      display: AUDIT            # Name shown (the name upper-cased by default)
+      severity: 35              # trace 10, debug 20, info 30 (default), warn 40,
+                                # error 50, dpanic 60, panic 70, fatal 80
+      color: level-audit        # Palette key, set under colors (level-<name> by default)
+
+Custom Worry Words:             # Words, phrases, and /patterns/ that get highlighted
   worries:
     info:                       # highlighted via worry-info
       - invalid
+      - /5\d\d/                 # A regular expression, between slashes
     warn:                       # highlighted via worry-warn
       - warning
     err:                        # highlighted via worry-err
@@ -260,11 +471,20 @@ Custom Worry Words:             # Words that get highlighted
       - failure
       - failed
       - fail
+      - connection refused      # A phrase of several words
     crit:                       # highlighted via worry-crit
       - fatal
+      - OOMKilled
+                                # A severity listed replaces its built-in words
+
+  worries_add:                  # Words added to the built-in ones, by severity
+    crit:
+      - OOMKilled
+  worries_remove:               # Words removed from the built-in ones
+    - invalid
 
 Custom Colors:
-  colors:                       # Override default color palette
+  colors:                       # Override colors of the theme's palette
     normal: "#dddddd"           # Normal text
     "date/time": "#dddddd"      # Timestamp color
     level-debug: "#6666ff"      # DEBUG level
@@ -276,16 +496,27 @@ Custom Colors:
     message: "#ffffff"          # Log message text
     stacktrace: "#ff6666"       # Stack traces
     data: "#aaaaaa"             # JSON data
-    data-literal: "#88aa88"     # JSON literals
+    data-key: "#87afd7"         # JSON keys
+    data-string: "#88aa88"      # JSON strings
+    data-number: "#d7af87"      # JSON numbers
+    data-bool: "#af87d7"        # JSON booleans
+    data-null: "#767676"        # JSON nulls
+    data-punct: "#aaaaaa"       # JSON punctuation
     worry-info: "#14ffff"       # Worry words (info)
     worry-err: "#ff0000"        # Worry words (error)
     worry-warn: "#ffff00"       # Worry words (warning)
     worry-crit: "#ff5f00"       # Worry words (critical)
     extracted: "#ff9999"        # Extracted field content
+    logger: "#8888cc"           # Logger name in aligned output
+    caller: "#767676"           # Caller in aligned output
+    identity-0: "#ff8787"       # Identity field values, through identity-11
 
 COLOR FORMATS:
   - Hex: "#ff0000" or "ff0000"
   - RGB: "rgb(255,0,0)" or "255,0,0"
+  - Style: a color, "on" and a background color, and any of bold, dim, italic,
+    underline, and reverse, like "bold #ffffff on #ff0000" or "dim". A style
+    without colors keeps the colors of the theme.
 
 EXAMPLE CONFIGURATION FILE (.logfmt.yaml):
   colorize: "on"
@@ -297,6 +528,7 @@ EXAMPLE CONFIGURATION FILE (.logfmt.yaml):
   colors:
     level-error: "#ff0000"
     level-warn: "255,255,0"
+    level-fatal: "bold #ffffff on #ff0000"
 
 INITIALIZATION:
   Create a default config file:
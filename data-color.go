@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+)
+
+// dataToken is a span of JSON text and the color it is shown in. An empty color
+// leaves the span as it is.
+type dataToken struct {
+	start, end int
+	color      ColorName
+}
+
+// tokenizeData splits JSON text into tokens for colorizing: keys, strings,
+// numbers, booleans, nulls, and punctuation. The tokens cover every byte of the
+// text in order, so joining them always gives back the original text, even if
+// it is not valid JSON. Anything unrecognized, like whitespace, is left
+// uncolored.
+//
+// The values of top-level fields named in valueColors are given that color
+// instead, which is how render rule thresholds color them. Worry words in
+// values are split out into tokens of their own, colored by severity, when
+// worry words are highlighted in the data.
+func tokenizeData(bs []byte, valueColors map[string]ColorName) []dataToken {
+	var (
+		tokens []dataToken
+		depth  int
+		key    string
+	)
+	for i := 0; i < len(bs); {
+		start := i
+		var color ColorName
+
+		switch b := bs[i]; {
+		case b == '"':
+			i = scanDataString(bs, i)
+			color = ColorDataString
+			if next := skipDataSpace(bs, i); next < len(bs) && bs[next] == ':' {
+				color = ColorDataKey
+				if depth == 1 {
+					key = ""
+					_ = json.Unmarshal(bs[start:i], &key)
+				}
+			}
+
+		case strings.IndexByte("{}[],:", b) >= 0:
+			i++
+			color = ColorDataPunct
+			switch b {
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			case ',':
+				key = ""
+			}
+
+		case b == '-' || (b >= '0' && b <= '9'):
+			i++
+			for i < len(bs) && strings.IndexByte("0123456789.eE+-", bs[i]) >= 0 {
+				i++
+			}
+			color = ColorDataNumber
+
+		case bytes.HasPrefix(bs[i:], []byte("true")):
+			i += len("true")
+			color = ColorDataBool
+
+		case bytes.HasPrefix(bs[i:], []byte("false")):
+			i += len("false")
+			color = ColorDataBool
+
+		case bytes.HasPrefix(bs[i:], []byte("null")):
+			i += len("null")
+			color = ColorDataNull
+
+		default:
+			_, size := utf8.DecodeRune(bs[i:])
+			i += size
+		}
+
+		isValue := color != ColorDataKey && color != ColorDataPunct && color != ""
+		if vc, ok := valueColors[key]; ok && depth == 1 && isValue {
+			color = vc
+		}
+
+		if isValue && highlightWorriesIn("data") {
+			tokens = appendWorryTokens(tokens, bs, start, i, color)
+			continue
+		}
+
+		// Merge runs of uncolored bytes into one token.
+		if n := len(tokens); color == "" && n > 0 && tokens[n-1].color == "" {
+			tokens[n-1].end = i
+			continue
+		}
+		tokens = append(tokens, dataToken{start, i, color})
+	}
+	return tokens
+}
+
+// appendWorryTokens appends the value from start to end as tokens, with any
+// worry words in it split out and colored by severity, and the rest in color.
+func appendWorryTokens(tokens []dataToken, bs []byte, start, end int, color ColorName) []dataToken {
+	last := start
+	for _, span := range findWorries(string(bs[start:end])) {
+		if start+span.start > last {
+			tokens = append(tokens, dataToken{last, start + span.start, color})
+		}
+		tokens = append(tokens, dataToken{start + span.start, start + span.end, span.color})
+		last = start + span.end
+	}
+	if end > last {
+		tokens = append(tokens, dataToken{last, end, color})
+	}
+	return tokens
+}
+
+// scanDataString returns the index just past the string starting with the
+// quote at i. An unterminated string runs to the end of the text.
+func scanDataString(bs []byte, i int) int {
+	for i++; i < len(bs); i++ {
+		switch bs[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(bs)
+}
+
+// skipDataSpace returns the index of the first byte at or after i that is not
+// JSON whitespace.
+func skipDataSpace(bs []byte, i int) int {
+	for i < len(bs) && strings.IndexByte(" \t\r\n", bs[i]) >= 0 {
+		i++
+	}
+	return i
+}
+
+// colorizeDataBytes colorizes JSON text, giving keys, strings, numbers,
+// booleans, nulls, and punctuation each their own color. See tokenizeData for
+// valueColors.
+func colorizeDataBytes(c *SugaredColorizer, bs []byte, valueColors map[string]ColorName) []byte {
+	return []byte(colorizeDataTokens(c, string(bs), tokenizeData(bs, valueColors), 0, len(bs)))
+}
+
+// colorizeDataTokens colorizes the part of s from start to end, which are byte
+// offsets, using the tokens found in all of s. This lets a part of the text be
+// colorized as it would be as a whole, as when wrapping.
+func colorizeDataTokens(c *SugaredColorizer, s string, tokens []dataToken, start, end int) string {
+	sb := &strings.Builder{}
+	for _, tok := range tokens {
+		from, to := max(tok.start, start), min(tok.end, end)
+		if from >= to {
+			continue
+		}
+
+		if tok.color == "" {
+			sb.WriteString(s[from:to])
+			continue
+		}
+		sb.WriteString(c.C(tok.color, s[from:to]))
+	}
+	return sb.String()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// TestColorizeDataBytesRoundTrip checks that stripping the color from the
+// colorized text gives back the original bytes, valid JSON or not.
+func TestColorizeDataBytesRoundTrip(t *testing.T) {
+	c := NewSugaredColorizer(NewColorOn(DefaultPalette, DepthTrueColor))
+
+	tests := []string{
+		`{"a1":1,"b":[true,false,null],"c":{"d":-1.5e+3}}`,
+		`{"esc":"é\"\\ 42","unicode":"héllo"}`,
+		`{ "spaced" : [ 1 , 2 ] }`,
+		`{"unterminated":"abc`,
+		`not json at all 123`,
+		``,
+	}
+
+	for _, test := range tests {
+		got := string(colorizeDataBytes(c, []byte(test), nil))
+		assert.Equal(t, test, ansiEscape.ReplaceAllString(got, ""), "round trip of %s", test)
+	}
+}
+
+// TestTokenizeData checks that each part of the JSON gets its color, and that
+// digits in keys and escapes are not taken for numbers.
+func TestTokenizeData(t *testing.T) {
+	bs := []byte(`{"id42":"1","n":7,"ok":true,"x":null}`)
+
+	var got []string
+	for _, tok := range tokenizeData(bs, nil) {
+		got = append(got, string(tok.color)+" "+string(bs[tok.start:tok.end]))
+	}
+
+	assert.Equal(t, []string{
+		"data-punct {",
+		`data-key "id42"`,
+		"data-punct :",
+		`data-string "1"`,
+		"data-punct ,",
+		`data-key "n"`,
+		"data-punct :",
+		"data-number 7",
+		"data-punct ,",
+		`data-key "ok"`,
+		"data-punct :",
+		"data-bool true",
+		"data-punct ,",
+		`data-key "x"`,
+		"data-punct :",
+		"data-null null",
+		"data-punct }",
+	}, got, "tokens")
+}
+
+// TestColorizeDataTokensPart checks that part of the text is colorized as it
+// is in the whole, even when it starts inside a string.
+func TestColorizeDataTokensPart(t *testing.T) {
+	c := NewSugaredColorizer(&ColorHTML{})
+	s := `{"k":"a b c"}`
+
+	got := markedToHTML(colorizeDataTokens(c, s, tokenizeData([]byte(s), nil), 8, len(s)))
+
+	assert.Equal(t, `<span class="c-data-string">b c&#34;</span><span class="c-data-punct">}</span>`, got, "string continues its color")
+}
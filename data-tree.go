@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// checkExpandMode rejects an --expand value that is not inline, tree, or a
+// number of characters. An empty value means the mode was never set, which
+// behaves as inline.
+func checkExpandMode() error {
+	switch expand {
+	case "", "inline", "tree":
+		return nil
+	}
+	if n, err := strconv.Atoi(expand); err == nil && n >= 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid --expand mode %q: expected inline, tree, or a number of characters", expand)
+}
+
+// expandData reports whether the trailing data, rendered inline as
+// lineDataBytes, is to be expanded into a tree according to --expand.
+func expandData(lineDataBytes []byte) bool {
+	switch expand {
+	case "", "inline":
+		return false
+	case "tree":
+		return true
+	}
+	n, err := strconv.Atoi(expand)
+	return err == nil && len(lineDataBytes) > n
+}
+
+// outputDataTree outputs the trailing data as an indented YAML-like tree below
+// the log entry, with keys, strings, numbers, booleans, and nulls each in their
+// own color. The values of top-level fields named in valueColors are given that
+// color instead.
+func outputDataTree(out io.Writer, c *SugaredColorizer, lineDataBytes []byte, valueColors map[string]ColorName) {
+	dec := json.NewDecoder(bytes.NewReader(lineDataBytes))
+	dec.UseNumber()
+
+	var data any
+	if err := dec.Decode(&data); err != nil {
+		return
+	}
+
+	sb := &strings.Builder{}
+	writeDataTree(sb, c, data, 4, valueColors)
+	_, _ = io.WriteString(out, sb.String())
+}
+
+// writeDataTree writes the value as tree lines indented by indent spaces. The
+// value is what decoding JSON with UseNumber produces.
+func writeDataTree(sb *strings.Builder, c *SugaredColorizer, v any, indent int, valueColors map[string]ColorName) {
+	pad := strings.Repeat(" ", indent)
+
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			sb.WriteString(pad + c.C(ColorDataKey, treeString(k)) + c.C(ColorData, ":"))
+			if vc, ok := valueColors[k]; ok && !isTreeContainer(v[k]) {
+				sb.WriteString(" " + treeColor(c, vc, treeScalarText(v[k])) + "\n")
+				continue
+			}
+			writeDataTreeChild(sb, c, v[k], indent)
+		}
+
+	case []any:
+		for _, item := range v {
+			if isTreeContainer(item) {
+				// Start the nested container on the dash line, YAML style,
+				// by swapping the dash in for the indent of its first line.
+				child := &strings.Builder{}
+				writeDataTree(child, c, item, indent+2, nil)
+				sb.WriteString(pad + c.C(ColorData, "-") + " " + child.String()[indent+2:])
+				continue
+			}
+
+			sb.WriteString(pad + c.C(ColorData, "-") + " " + treeScalar(c, item) + "\n")
+		}
+
+	default:
+		sb.WriteString(pad + treeScalar(c, v) + "\n")
+	}
+}
+
+// writeDataTreeChild finishes the line of a key with its value, either on the
+// same line or as a nested tree below it.
+func writeDataTreeChild(sb *strings.Builder, c *SugaredColorizer, v any, indent int) {
+	if isTreeContainer(v) {
+		sb.WriteString("\n")
+		writeDataTree(sb, c, v, indent+2, nil)
+		return
+	}
+	sb.WriteString(" " + treeScalar(c, v) + "\n")
+}
+
+// isTreeContainer reports whether the value is a non-empty object or array,
+// which is written as a nested tree. Empty ones are written as {} and [].
+func isTreeContainer(v any) bool {
+	switch v := v.(type) {
+	case map[string]any:
+		return len(v) > 0
+	case []any:
+		return len(v) > 0
+	}
+	return false
+}
+
+// treeScalar renders a value written on a single line of the tree.
+func treeScalar(c *SugaredColorizer, v any) string {
+	color := ColorData
+	switch v.(type) {
+	case nil:
+		color = ColorDataNull
+	case bool:
+		color = ColorDataBool
+	case json.Number:
+		color = ColorDataNumber
+	case string:
+		color = ColorDataString
+	}
+	return treeColor(c, color, treeScalarText(v))
+}
+
+// treeColor colors the text of a value, with its worry words colored by
+// severity when worry words are highlighted in the data.
+func treeColor(c *SugaredColorizer, color ColorName, text string) string {
+	if highlightWorriesIn("data") {
+		return colorWorries(c, text, color)
+	}
+	return c.C(color, text)
+}
+
+// treeScalarText renders the text of a value written on a single line of the
+// tree.
+func treeScalarText(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	case string:
+		return treeString(v)
+	case map[string]any:
+		return "{}"
+	case []any:
+		return "[]"
+	}
+	return fmt.Sprint(v)
+}
+
+// treePlainString matches strings that can be written in the tree without
+// quotes without being mistaken for something else.
+var treePlainString = regexp.MustCompile(`^[^\s"'{}\[\],&*#?|<>=!%@` + "`" + `-][^\n\t"]*$`)
+
+// treeAmbiguous matches strings that would read as a number, boolean, or null
+// if they were not quoted.
+var treeAmbiguous = regexp.MustCompile(`^(?:[-+]?[0-9][0-9_.eE+-]*|true|false|null|~)$`)
+
+// treeString renders a key or string value, quoted as JSON only when it has to
+// be.
+func treeString(s string) string {
+	if treePlainString.MatchString(s) &&
+		!treeAmbiguous.MatchString(s) &&
+		!strings.HasSuffix(s, " ") &&
+		!strings.HasSuffix(s, ":") &&
+		!strings.Contains(s, ": ") &&
+		!strings.Contains(s, " #") {
+		return s
+	}
+
+	bs, _ := marshalJSON(s)
+	return string(bs)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestOutputDataTree checks the layout of nested objects and arrays, and when
+// strings need quotes.
+func TestOutputDataTree(t *testing.T) {
+	out := &bytes.Buffer{}
+	c := NewSugaredColorizer(&ColorOff{})
+
+	outputDataTree(out, c, []byte(`{"order":{"id":"A-17","items":[{"sku":"x1","qty":2},[1,"two"]],"meta":{},"tags":[],"note":null,"gift":false},"code":"007","say":"a: b","big":12345678901234567890}`), nil)
+
+	assert.Equal(t, ""+
+		"    big: 12345678901234567890\n"+
+		"    code: \"007\"\n"+
+		"    order:\n"+
+		"      gift: false\n"+
+		"      id: A-17\n"+
+		"      items:\n"+
+		"        - qty: 2\n"+
+		"          sku: x1\n"+
+		"        - - 1\n"+
+		"          - two\n"+
+		"      meta: {}\n"+
+		"      note: null\n"+
+		"      tags: []\n"+
+		"    say: \"a: b\"\n",
+		out.String(), "tree layout")
+}
+
+// TestOutputDataTreeColors checks that keys and each kind of value get their
+// own color.
+func TestOutputDataTreeColors(t *testing.T) {
+	out := &bytes.Buffer{}
+	c := NewSugaredColorizer(&ColorHTML{})
+
+	outputDataTree(out, c, []byte(`{"k":"v","n":1,"b":true,"z":null}`), nil)
+
+	page := markedToHTML(out.String())
+	assert.Contains(t, page, `<span class="c-data-key">k</span>`, "key")
+	assert.Contains(t, page, `<span class="c-data-string">v</span>`, "string")
+	assert.Contains(t, page, `<span class="c-data-number">1</span>`, "number")
+	assert.Contains(t, page, `<span class="c-data-bool">true</span>`, "boolean")
+	assert.Contains(t, page, `<span class="c-data-null">null</span>`, "null")
+}
+
+// TestExpandMode covers the accepted --expand values and when each expands.
+func TestExpandMode(t *testing.T) {
+	orig := expand
+	t.Cleanup(func() { expand = orig })
+
+	data := []byte(`{"a":1,"b":2}`)
+
+	expand = "inline"
+	assert.NoError(t, checkExpandMode(), "inline is valid")
+	assert.False(t, expandData(data), "inline never expands")
+
+	expand = "tree"
+	assert.NoError(t, checkExpandMode(), "tree is valid")
+	assert.True(t, expandData(data), "tree always expands")
+
+	expand = "10"
+	assert.NoError(t, checkExpandMode(), "a length is valid")
+	assert.True(t, expandData(data), "longer than the length expands")
+
+	expand = "20"
+	assert.False(t, expandData(data), "shorter than the length stays inline")
+
+	expand = "yaml"
+	assert.Error(t, checkExpandMode(), "anything else is rejected")
+}
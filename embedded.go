@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// decodeEmbeddedJSON replaces string values holding a JSON object or array
+// with the decoded structure, so they render as nested data rather than as an
+// escaped string. Nested values are searched too, which also unwraps JSON that
+// was encoded more than once. Extracted fields are left alone, since they are
+// displayed as text.
+//
+// A message that ends in a JSON object, as in `request failed {"code":42}`,
+// is split the same way the zap console parser splits its fields off: the
+// object is merged into the entry and only the text before it is kept as the
+// message. Keys the entry already has are kept under "<msg-field>.<key>"
+// instead of being overwritten.
+func decodeEmbeddedJSON(lineData map[string]any) {
+	for k, v := range lineData {
+		if k == msgField || slices.Contains(extractFields, k) {
+			continue
+		}
+		lineData[k] = decodeEmbeddedValue(v)
+	}
+
+	msg, err := getString(lineData, msgField)
+	if err != nil || strings.TrimSpace(msg) == "" {
+		return
+	}
+
+	structure, remainingMsg, err := parseStructure([]byte(msg))
+	if err != nil {
+		return
+	}
+
+	for k, v := range structure {
+		if _, exists := lineData[k]; exists {
+			k = msgField + "." + k
+		}
+		lineData[k] = decodeEmbeddedValue(v)
+	}
+	lineData[msgField] = strings.TrimRight(string(remainingMsg), WS)
+}
+
+// decodeEmbeddedValue decodes v if it is a string holding a JSON object or
+// array, and searches maps and slices for more of the same.
+func decodeEmbeddedValue(v any) any {
+	switch v := v.(type) {
+	case string:
+		s := strings.TrimSpace(v)
+		if len(s) < 2 {
+			return v
+		}
+		if !(s[0] == '{' && s[len(s)-1] == '}') && !(s[0] == '[' && s[len(s)-1] == ']') {
+			return v
+		}
+
+		var decoded any
+		if err := json.Unmarshal([]byte(s), &decoded); err != nil {
+			return v
+		}
+		return decodeEmbeddedValue(decoded)
+	case map[string]any:
+		for k, mv := range v {
+			v[k] = decodeEmbeddedValue(mv)
+		}
+	case []any:
+		for i, sv := range v {
+			v[i] = decodeEmbeddedValue(sv)
+		}
+	}
+	return v
+}
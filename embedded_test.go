@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDecodeEmbeddedJSONFields checks that JSON held in string fields is
+// decoded, including JSON nested inside JSON, and that other strings are left
+// alone.
+func TestDecodeEmbeddedJSONFields(t *testing.T) {
+	lineData := map[string]any{
+		"msg":     "payload received",
+		"payload": `{"event":"x","inner":"{\"n\":1}"}`,
+		"list":    ` [1, 2] `,
+		"request": map[string]any{"body": `{"ok":true}`},
+		"error":   `{"code":500}`,
+		"text":    "{not json}",
+		"number":  "42",
+	}
+
+	decodeEmbeddedJSON(lineData)
+
+	assert.Equal(t, map[string]any{"event": "x", "inner": map[string]any{"n": 1.0}}, lineData["payload"], "object decoded, twice-encoded too")
+	assert.Equal(t, []any{1.0, 2.0}, lineData["list"], "array decoded")
+	assert.Equal(t, map[string]any{"body": map[string]any{"ok": true}}, lineData["request"], "nested string decoded")
+	assert.Equal(t, `{"code":500}`, lineData["error"], "extracted fields left as text")
+	assert.Equal(t, "{not json}", lineData["text"], "invalid JSON left alone")
+	assert.Equal(t, "42", lineData["number"], "scalar JSON left alone")
+	assert.Equal(t, "payload received", lineData["msg"], "plain message untouched")
+}
+
+// TestDecodeEmbeddedJSONMessage checks that a JSON object at the end of the
+// message, or making up all of it, is moved into the fields.
+func TestDecodeEmbeddedJSONMessage(t *testing.T) {
+	lineData := map[string]any{
+		"msg":      `request failed {"code":42,"upstream":"cart","detail":"{\"retry\":false}"}`,
+		"upstream": "proxy",
+	}
+
+	decodeEmbeddedJSON(lineData)
+
+	assert.Equal(t, "request failed", lineData["msg"], "object removed from the message")
+	assert.Equal(t, 42.0, lineData["code"], "object merged into the fields")
+	assert.Equal(t, map[string]any{"retry": false}, lineData["detail"], "embedded values decoded")
+	assert.Equal(t, "proxy", lineData["upstream"], "existing field not overwritten")
+	assert.Equal(t, "cart", lineData["msg.upstream"], "colliding key kept under the message field")
+
+	lineData = map[string]any{"msg": `{"event":"x"}`}
+
+	decodeEmbeddedJSON(lineData)
+
+	assert.Equal(t, "", lineData["msg"], "message that is all JSON is emptied")
+	assert.Equal(t, "x", lineData["event"], "message JSON merged into the fields")
+
+	for _, msg := range []string{"", "   ", "closing brace } only", "odd {braces}"} {
+		lineData = map[string]any{"msg": msg}
+
+		decodeEmbeddedJSON(lineData)
+
+		assert.Equal(t, msg, lineData["msg"], "message %q left alone", msg)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// escalatedLevel is the level of an entry raised by escalateLevel. No parsed
+// entry has a value of this type, so it cannot be mistaken for one a logger
+// wrote.
+type escalatedLevel struct {
+	name string
+}
+
+// String returns the level marked as inferred, as in ~error.
+func (l escalatedLevel) String() string {
+	return "~" + l.name
+}
+
+// MarshalJSON writes the level name, should it be written with the trailing
+// fields, since the marked level is not one other tools know.
+func (l escalatedLevel) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.name)
+}
+
+// levelInferredKey is the key the logfmt, JSON, CSV, and TSV formats flag an
+// escalated level with, leaving the level itself one other tools know.
+const levelInferredKey = "level_inferred"
+
+// worryEscalations are the levels an entry is raised to when its message has a
+// worry word of the severity. Worry words of lesser severities leave the level
+// alone.
+var worryEscalations = map[WorrySeverity]string{
+	WorryErr:  "error",
+	WorryCrit: "fatal",
+}
+
+// escalateLevel raises the level of an entry whose message has an err or crit
+// worry word, as with libraries that log "request failed" at info. The level is
+// never lowered. The raised level is stored as an escalatedLevel, which
+// getLevel reads like any other and markEscalated marks.
+func escalateLevel(lineData map[string]any) {
+	msg, err := getString(lineData, msgField)
+	if err != nil || msg == "" {
+		return
+	}
+
+	worst := WorrySeverity(WorryNone)
+	for _, span := range findWorries(msg) {
+		worst = max(worst, span.severity)
+	}
+
+	name, ok := worryEscalations[worst]
+	if !ok {
+		return
+	}
+
+	target, ok := Levels[name]
+	if !ok {
+		return
+	}
+
+	level := getLevel(lineData)
+	if current, ok := lookupLevel(level); ok && current.Severity >= target.Severity {
+		return
+	}
+
+	lineData[lvlField] = escalatedLevel{target.Name}
+}
+
+// levelInferred reports whether the level of the entry was raised by
+// escalateLevel.
+func levelInferred(lineData map[string]any) bool {
+	_, ok := lineData[lvlField].(escalatedLevel)
+	return ok
+}
+
+// markEscalated marks the level shown for an entry whose level was raised by
+// escalateLevel, as in ~ERROR, the same way in every format meant for reading.
+func markEscalated(lineData map[string]any, level string) string {
+	if !levelInferred(lineData) {
+		return level
+	}
+	return "~" + level
+}
+
+// checkMinLevel rejects a --min-level that is not a known level or alias. It
+// is checked after setupLevels, so custom levels are accepted.
+func checkMinLevel() error {
+	if minLevel == "" {
+		return nil
+	}
+	if _, ok := lookupLevel(minLevel); !ok {
+		var names []string
+		for _, l := range sortedLevels() {
+			names = append(names, l.Name)
+		}
+		return fmt.Errorf("invalid --min-level %q: expected one of %s", minLevel, strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// meetsMinLevel reports whether the entry is at least as severe as --min-level.
+// Entries without a level, or with a level that is not known, are always shown,
+// since there is no telling how severe they are.
+func meetsMinLevel(lineData map[string]any) bool {
+	if minLevel == "" {
+		return true
+	}
+
+	floor, ok := lookupLevel(minLevel)
+	if !ok {
+		return true
+	}
+
+	level := getLevel(lineData)
+	l, ok := lookupLevel(level)
+	return !ok || l.Severity >= floor.Severity
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEscalateLevel covers raising the level for err and crit worry words,
+// and leaving lesser worries and more severe levels alone.
+func TestEscalateLevel(t *testing.T) {
+	tests := []struct {
+		name      string
+		entry     map[string]any
+		level     string
+		escalated bool
+	}{
+		{"err worry at info", map[string]any{"level": "info", "msg": "request failed"}, "error", true},
+		{"crit worry at warn", map[string]any{"level": "W", "msg": "pod OOMKilled"}, "fatal", true},
+		{"most severe worry wins", map[string]any{"level": "debug", "msg": "error, then OOMKilled"}, "fatal", true},
+		{"no level", map[string]any{"msg": "connection refused"}, "error", true},
+		{"unknown level", map[string]any{"level": "chatty", "msg": "failed"}, "error", true},
+		{"numeric level", map[string]any{"level": 30.0, "msg": "failed"}, "error", true},
+		{"warn worry", map[string]any{"level": "info", "msg": "warning: slow"}, "info", false},
+		{"already as severe", map[string]any{"level": "error", "msg": "failed"}, "error", false},
+		{"never lowered", map[string]any{"level": "fatal", "msg": "failed"}, "fatal", false},
+		{"no worry", map[string]any{"level": "info", "msg": "all good"}, "info", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			escalateLevel(tt.entry)
+
+			assert.Equal(t, tt.level, getLevel(tt.entry), "level")
+			assert.Equal(t, tt.escalated, markEscalated(tt.entry, "x") == "~x", "marked as escalated")
+		})
+	}
+}
+
+// TestEscalateLevelMarker checks that the formats meant for reading mark an
+// escalated level, that the formats meant for tools keep the level and flag it
+// with level_inferred instead, and that an entry with fields of those names is
+// left alone.
+func TestEscalateLevelMarker(t *testing.T) {
+	c := NewSugaredColorizer(NewColorOn(DefaultPalette, DepthTrueColor))
+	trims := []string{"level", "msg", "ts"}
+	format := `{{index . "msg"}}`
+
+	escalated := func() map[string]any {
+		entry := map[string]any{"level": "info", "msg": "request failed", "id": 7.0}
+		escalateLevel(entry)
+		return entry
+	}
+
+	console := &bytes.Buffer{}
+	outputFormattedLogLine(console, c, escalated(), "ts", format, trims, &TimeDisplay{})
+	assert.Contains(t, console.String(), c.C(ColorLevelError, "~ERROR"), "console marked and colored as error")
+
+	logfmt := &bytes.Buffer{}
+	NewLogfmtFormatter(logfmt, "ts", format, trims).FormatEntry(escalated())
+	assert.Equal(t, "level=error level_inferred=true msg=\"request failed\" id=7\n", logfmt.String(), "logfmt")
+
+	js := &bytes.Buffer{}
+	NewJSONFormatter(js, "ts", format, trims).FormatEntry(escalated())
+	assert.Equal(t, `{"level":"error","level_inferred":true,"msg":"request failed","id":7}`+"\n", js.String(), "JSON")
+
+	csv := &bytes.Buffer{}
+	cf := NewCSVFormatter(csv, ',', "ts", format, []string{"level", "level_inferred", "msg"})
+	cf.FormatEntry(escalated())
+	cf.FormatEntry(map[string]any{"level": "info", "msg": "hi"})
+	assert.Equal(t, "level,level_inferred,msg\nerror,true,request failed\ninfo,false,hi\n", csv.String(), "CSV")
+
+	data := map[string]any{"level": "info", "msg": "hi", "escalated_from": "info", "level_inferred": "no"}
+	plain := &bytes.Buffer{}
+	NewLogfmtFormatter(plain, "ts", format, trims).FormatEntry(data)
+	assert.Equal(t, "level=info msg=hi escalated_from=info fields.level_inferred=no\n", plain.String(), "fields of the entry are left alone")
+}
+
+// TestMeetsMinLevel covers filtering by severity, with aliases and custom
+// levels, and always showing entries whose level is missing or unknown.
+func TestMeetsMinLevel(t *testing.T) {
+	orig := minLevel
+	t.Cleanup(func() {
+		minLevel = orig
+		_ = setupLevels(DefaultConfig())
+	})
+
+	c := DefaultConfig()
+	c.Levels = []LevelConfig{{Name: "audit", Severity: 45}}
+	require.NoError(t, setupLevels(c))
+
+	minLevel = "warning"
+	for level, want := range map[string]bool{
+		"debug": false,
+		"info":  false,
+		"warn":  true,
+		"audit": true,
+		"E":     true,
+		"fatal": true,
+		"":      true,
+		"weird": true,
+	} {
+		assert.Equal(t, want, meetsMinLevel(map[string]any{"level": level}), "level %q", level)
+	}
+	assert.True(t, meetsMinLevel(map[string]any{"msg": "no level"}), "no level field")
+
+	minLevel = ""
+	assert.True(t, meetsMinLevel(map[string]any{"level": "trace"}), "no minimum")
+}
+
+// TestEscalateBeforeMinLevel checks that an escalated entry passes a
+// --min-level its original level would not.
+func TestEscalateBeforeMinLevel(t *testing.T) {
+	orig := minLevel
+	t.Cleanup(func() { minLevel = orig })
+
+	minLevel = "error"
+	entry := map[string]any{"level": "info", "msg": "request failed"}
+	require.False(t, meetsMinLevel(entry), "info is filtered out")
+
+	escalateLevel(entry)
+	assert.True(t, meetsMinLevel(entry), "escalated to error")
+}
+
+// TestEscalateWorriesDefaultConfig runs --escalate-worries with the built-in
+// worry words, as in an install without a configuration file.
+func TestEscalateWorriesDefaultConfig(t *testing.T) {
+	input := `{"level":"info","msg":"request failed"}` + "\n" + `{"level":"info","msg":"all good"}` + "\n"
+
+	out := runLogfmt(t, input, "--escalate-worries", "--min-level", "warn")
+	assert.Contains(t, out, "~ERROR", "escalated to error and marked")
+	assert.Contains(t, out, "request failed", "failure shown")
+	assert.NotContains(t, out, "all good", "info filtered out")
+
+	out = runLogfmt(t, input, "--escalate-worries", "--min-level", "warn", "--output-format", "json")
+	assert.Equal(t, `{"level":"error","level_inferred":true,"msg":"request failed"}`+"\n", out, "JSON keeps a level other tools know")
+}
+
+// TestCheckMinLevel covers known levels and aliases and the rejection of
+// anything else.
+func TestCheckMinLevel(t *testing.T) {
+	orig := minLevel
+	t.Cleanup(func() { minLevel = orig })
+
+	for _, level := range []string{"", "warn", "WARNING", "e", "trace"} {
+		minLevel = level
+		assert.NoError(t, checkMinLevel(), "%q is a valid level", level)
+	}
+
+	minLevel = "loud"
+
+	err := checkMinLevel()
+
+	require.Error(t, err, "unknown level is rejected")
+	assert.Contains(t, err.Error(), "loud", "error names the bad level")
+	assert.Contains(t, err.Error(), "trace, debug, info, warn, error, dpanic, panic, fatal", "error lists the levels by severity")
+}
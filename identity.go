@@ -0,0 +1,25 @@
+package main
+
+import (
+	"hash/fnv"
+)
+
+// identityColor picks the color of an identity field's value by hashing it.
+func identityColor(v any) ColorName {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(fieldString(v)))
+	return IdentityColors[h.Sum32()%uint32(len(IdentityColors))]
+}
+
+// identityColors returns the colors of the values of the identity fields in the
+// log entry, by field name, for colorizing the trailing data. The map is never
+// nil, so other colors may be added to it.
+func identityColors(lineData map[string]any) map[string]ColorName {
+	valueColors := map[string]ColorName{}
+	for _, field := range identityFields {
+		if v, ok := lineData[field]; ok && v != nil {
+			valueColors[field] = identityColor(v)
+		}
+	}
+	return valueColors
+}
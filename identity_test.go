@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestIdentityColor checks that a value always gets the same color, and that
+// different values are spread over the colors.
+func TestIdentityColor(t *testing.T) {
+	assert.Equal(t, identityColor("req-7f3a"), identityColor("req-7f3a"), "same value, same color")
+	assert.Equal(t, identityColor(42.0), identityColor("42"), "numbers hash as their text")
+
+	seen := map[ColorName]bool{}
+	for _, id := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"} {
+		seen[identityColor(id)] = true
+	}
+	assert.Greater(t, len(seen), 4, "values are spread over the colors")
+}
+
+// TestIdentityColors checks that the identity fields are colored in the
+// trailing data, and nothing else is.
+func TestIdentityColors(t *testing.T) {
+	orig := identityFields
+	t.Cleanup(func() { identityFields = orig })
+	identityFields = []string{"request_id", "goroutine"}
+
+	out := &bytes.Buffer{}
+	c := NewSugaredColorizer(&ColorHTML{})
+	outputFormattedLogLine(out, c, map[string]any{"msg": "hi", "request_id": "req-7f3a", "user": "alice"}, "ts", `{{with index . "msg"}}{{.}}{{end}}`, []string{"msg", "ts"}, &TimeDisplay{})
+
+	page := markedToHTML(out.String())
+	assert.Contains(t, page, `<span class="`+htmlColorClass(identityColor("req-7f3a"))+`">&#34;req-7f3a&#34;</span>`, "identity colored")
+	assert.Contains(t, page, `<span class="c-data-string">&#34;alice&#34;</span>`, "other fields as usual")
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// k8sAuditMarker is the API group every Kubernetes audit event names in its
+// apiVersion. It is checked before decoding, so ordinary JSON log lines are not
+// decoded twice.
+var k8sAuditMarker = []byte(`"audit.k8s.io/`)
+
+// k8sAuditSummaryFields are the fields of an audit event that end up in the
+// synthesized message or level, so they are not repeated in the trailing data.
+var k8sAuditSummaryFields = []string{
+	"kind",
+	"apiVersion",
+	"verb",
+	"user",
+	"objectRef",
+	"responseStatus",
+	"requestReceivedTimestamp",
+	"stageTimestamp",
+}
+
+// parseK8sAuditLogLine parses a Kubernetes API server audit event. Audit
+// events have no message, so one is synthesized from who did what to which
+// object, e.g., "alice GET pods/default/foo 200". The level is derived from the
+// response code, and the failure message of an unsuccessful request is reported
+// as the error. The audit level of the event, which would otherwise collide
+// with the log level, is kept as "audit_level".
+func parseK8sAuditLogLine(line []byte, tsField string) (map[string]any, error) {
+	if !bytes.Contains(line, k8sAuditMarker) {
+		return nil, ErrUnparseable
+	}
+
+	lineData := map[string]any{}
+	if err := json.Unmarshal(line, &lineData); err != nil {
+		return nil, err
+	}
+
+	kind, _ := getString(lineData, "kind")
+	apiVersion, _ := getString(lineData, "apiVersion")
+	if kind != "Event" || !strings.HasPrefix(apiVersion, "audit.k8s.io/") {
+		return nil, ErrUnparseable
+	}
+
+	verb, _ := getString(lineData, "verb")
+	summary := []string{k8sAuditUsername(lineData), strings.ToUpper(verb), k8sAuditObject(lineData)}
+
+	level := "info"
+	status, _ := lineData["responseStatus"].(map[string]any)
+	if code, err := getFloat64(status, "code"); err == nil {
+		summary = append(summary, fmt.Sprintf("%.0f", code))
+		level = k8sAuditCodeLevel(code)
+	}
+	if level != "info" {
+		if msg, err := getString(status, "message"); err == nil && msg != "" {
+			lineData["error"] = msg
+		}
+	}
+
+	ts, err := getString(lineData, "stageTimestamp")
+	if err != nil {
+		ts, _ = getString(lineData, "requestReceivedTimestamp")
+	}
+
+	if auditLevel, ok := lineData["level"]; ok {
+		lineData["audit_level"] = auditLevel
+		delete(lineData, "level")
+	}
+
+	for _, field := range k8sAuditSummaryFields {
+		delete(lineData, field)
+	}
+
+	lineData[tsField] = ts
+	lineData[lvlField] = level
+	lineData[msgField] = strings.Join(summary, " ")
+
+	convertGenericTimestampToTime(lineData, tsField)
+
+	return lineData, nil
+}
+
+// k8sAuditUsername names the user who made the request, preferring the user
+// being impersonated, if any.
+func k8sAuditUsername(lineData map[string]any) string {
+	for _, field := range []string{"impersonatedUser", "user"} {
+		user, _ := lineData[field].(map[string]any)
+		if username, err := getString(user, "username"); err == nil {
+			return username
+		}
+	}
+	return "-"
+}
+
+// k8sAuditObject names the object of the request as
+// resource/namespace/name/subresource, leaving out whichever parts are unset.
+// Requests that are not about an object, like /healthz, are named by URI.
+func k8sAuditObject(lineData map[string]any) string {
+	ref, _ := lineData["objectRef"].(map[string]any)
+
+	var parts []string
+	for _, field := range []string{"resource", "namespace", "name", "subresource"} {
+		if part, err := getString(ref, field); err == nil && part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) > 0 {
+		return strings.Join(parts, "/")
+	}
+
+	if uri, err := getString(lineData, "requestURI"); err == nil {
+		return uri
+	}
+	return "-"
+}
+
+// k8sAuditCodeLevel maps an HTTP response code to a log level.
+func k8sAuditCodeLevel(code float64) string {
+	switch {
+	case code >= 500:
+		return "error"
+	case code >= 400:
+		return "warn"
+	}
+	return "info"
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseK8sAuditLogLine checks the synthesized summary and that the fields
+// it is built from are dropped from the trailing data.
+func TestParseK8sAuditLogLine(t *testing.T) {
+	line := `{"kind":"Event","apiVersion":"audit.k8s.io/v1","level":"Metadata","auditID":"7e0cbccf","stage":"ResponseComplete","requestURI":"/api/v1/namespaces/default/pods/foo","verb":"get","user":{"username":"alice","groups":["system:authenticated"]},"sourceIPs":["10.0.0.7"],"objectRef":{"resource":"pods","namespace":"default","name":"foo","apiVersion":"v1"},"responseStatus":{"metadata":{},"code":200},"requestReceivedTimestamp":"2026-08-13T14:22:03.100000Z","stageTimestamp":"2026-08-13T14:22:03.117000Z"}`
+
+	lineData, err := parseK8sAuditLogLine([]byte(line), "ts")
+	require.NoError(t, err, "audit event parses")
+
+	assert.Equal(t, "alice GET pods/default/foo 200", lineData["msg"], "summary synthesized")
+	assert.Equal(t, "info", lineData["level"], "2xx is info")
+	assert.Equal(t, "Metadata", lineData["audit_level"], "audit level kept apart from the log level")
+	assert.Equal(t, time.Date(2026, 8, 13, 14, 22, 3, 117_000_000, time.UTC), lineData["ts"].(time.Time).UTC(), "stage timestamp used")
+	assert.Equal(t, "7e0cbccf", lineData["auditID"], "other fields kept")
+	for _, field := range []string{"kind", "apiVersion", "verb", "user", "objectRef", "responseStatus", "stageTimestamp"} {
+		assert.NotContains(t, lineData, field, "%s is shown in the summary", field)
+	}
+}
+
+// TestParseK8sAuditLogLineFailures checks the level derived from failing
+// response codes and the summary of requests without an object.
+func TestParseK8sAuditLogLineFailures(t *testing.T) {
+	notFound := `{"kind":"Event","apiVersion":"audit.k8s.io/v1","verb":"delete","user":{"username":"bob"},"objectRef":{"resource":"nodes","name":"node-1"},"responseStatus":{"status":"Failure","message":"nodes \"node-1\" not found","code":404},"stageTimestamp":"2026-08-13T14:22:03Z"}`
+
+	lineData, err := parseK8sAuditLogLine([]byte(notFound), "ts")
+	require.NoError(t, err, "audit event parses")
+	assert.Equal(t, "bob DELETE nodes/node-1 404", lineData["msg"], "cluster-scoped object has no namespace")
+	assert.Equal(t, "warn", lineData["level"], "4xx is a warning")
+	assert.Equal(t, `nodes "node-1" not found`, lineData["error"], "failure message reported as the error")
+
+	healthz := `{"kind":"Event","apiVersion":"audit.k8s.io/v1","stage":"ResponseComplete","verb":"get","requestURI":"/healthz","user":{"username":"system:anonymous"},"responseStatus":{"code":503}}`
+
+	lineData, err = parseK8sAuditLogLine([]byte(healthz), "ts")
+	require.NoError(t, err, "audit event parses")
+	assert.Equal(t, "system:anonymous GET /healthz 503", lineData["msg"], "non-resource request named by URI")
+	assert.Equal(t, "error", lineData["level"], "5xx is an error")
+
+	received := `{"kind":"Event","apiVersion":"audit.k8s.io/v1","stage":"RequestReceived","verb":"watch","user":{"username":"alice"},"objectRef":{"resource":"pods","namespace":"default"}}`
+
+	lineData, err = parseK8sAuditLogLine([]byte(received), "ts")
+	require.NoError(t, err, "audit event parses")
+	assert.Equal(t, "alice WATCH pods/default", lineData["msg"], "no code before the response")
+	assert.Equal(t, "info", lineData["level"], "no response is info")
+}
+
+// TestParseK8sAuditLogLineRejectsOtherJSON makes sure other JSON, including
+// core Kubernetes events and audit policies, is left to the regular JSON parser.
+func TestParseK8sAuditLogLineRejectsOtherJSON(t *testing.T) {
+	for _, line := range []string{
+		`{"level":"info","msg":"hi"}`,
+		`{"kind":"Event","apiVersion":"v1","reason":"Pulled","message":"Successfully pulled image"}`,
+		`{"kind":"Policy","apiVersion":"audit.k8s.io/v1","rules":[{"level":"Metadata"}]}`,
+	} {
+		_, err := parseK8sAuditLogLine([]byte(line), "ts")
+		assert.ErrorIs(t, err, ErrUnparseable, "%s is not an audit event", line)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Level is a log level: its canonical lowercase name, the name it is shown as,
+// how severe it is compared to other levels, and the palette color it is
+// shown in.
+type Level struct {
+	Name     string
+	Display  string
+	Severity int
+	Color    ColorName
+}
+
+// LevelConfig is a custom level as written in the configuration.
+type LevelConfig struct {
+	Name     string   `yaml:"name" mapstructure:"name"`
+	Display  string   `yaml:"display,omitempty" mapstructure:"display"`
+	Aliases  []string `yaml:"aliases,omitempty" mapstructure:"aliases"`
+	Severity int      `yaml:"severity,omitempty" mapstructure:"severity"`
+	Color    string   `yaml:"color,omitempty" mapstructure:"color"`
+}
+
+// builtinLevels are the levels logfmt knows without configuration, from least
+// to most severe. The severities leave room for custom levels in between.
+var builtinLevels = []Level{
+	{"trace", "TRACE", 10, ColorLevelDebug},
+	{"debug", "DEBUG", 20, ColorLevelDebug},
+	{"info", "INFO", 30, ColorLevelInfo},
+	{"warn", "WARN", 40, ColorLevelWarn},
+	{"error", "ERROR", 50, ColorLevelError},
+	{"dpanic", "DPANIC", 60, ColorLevelDPanic},
+	{"panic", "PANIC", 70, ColorLevelFatal},
+	{"fatal", "FATAL", 80, ColorLevelFatal},
+}
+
+// builtinLevelAliases maps the level names used by other loggers onto the
+// canonical level names.
+var builtinLevelAliases = map[string]string{
+	"verbose":       "trace",
+	"information":   "info",
+	"informational": "info",
+	"notice":        "info",
+	"note":          "info",
+	"log":           "info",
+	"system":        "info",
+	"warning":       "warn",
+	"err":           "error",
+	"critical":      "fatal",
+	"crit":          "fatal",
+	"alert":         "fatal",
+	"emerg":         "fatal",
+	"emergency":     "fatal",
+
+	// The single letters of glog, klog, and Android's logcat.
+	"v": "trace",
+	"t": "trace",
+	"d": "debug",
+	"i": "info",
+	"w": "warn",
+	"e": "error",
+	"f": "fatal",
+
+	// The numeric levels of bunyan and pino.
+	"10": "trace",
+	"20": "debug",
+	"30": "info",
+	"40": "warn",
+	"50": "error",
+	"60": "fatal",
+
+	// Emoji used by pretty loggers.
+	"🐛":  "debug",
+	"🔍":  "debug",
+	"ℹ":  "info",
+	"ℹ️": "info",
+	"💡":  "info",
+	"⚠":  "warn",
+	"⚠️": "warn",
+	"❌":  "error",
+	"🚨":  "error",
+	"🔥":  "fatal",
+	"💀":  "fatal",
+}
+
+// Levels are the known levels by canonical name, and levelAliases map other
+// names onto them. Both start out with the built-in levels, and setupLevels
+// adds the custom levels in the configuration.
+var (
+	Levels       = indexLevels(builtinLevels)
+	levelAliases = maps.Clone(builtinLevelAliases)
+)
+
+// indexLevels maps the levels by name.
+func indexLevels(levels []Level) map[string]*Level {
+	index := make(map[string]*Level, len(levels))
+	for _, l := range levels {
+		index[l.Name] = &l
+	}
+	return index
+}
+
+// setupLevels adds the custom levels in the configuration to the built-in
+// levels. A custom level with the name of a built-in level replaces it, keeping
+// the severity and color it does not set.
+func setupLevels(c *Config) error {
+	levels := indexLevels(builtinLevels)
+	aliases := maps.Clone(builtinLevelAliases)
+
+	for _, lc := range c.Levels {
+		name := strings.ToLower(strings.TrimSpace(lc.Name))
+		if name == "" {
+			return fmt.Errorf("custom level has no name")
+		}
+
+		// A redefined level keeps what it does not set, so renaming error does
+		// not also make it as severe as info.
+		base, redefined := levels[name]
+		if !redefined {
+			base = &Level{
+				Severity: levels["info"].Severity,
+				Color:    ColorName("level-" + name),
+			}
+		}
+
+		levels[name] = &Level{
+			Name:     name,
+			Display:  cmp.Or(lc.Display, strings.ToUpper(name)),
+			Severity: cmp.Or(lc.Severity, base.Severity),
+			Color:    cmp.Or(ColorName(lc.Color), base.Color),
+		}
+		delete(aliases, name)
+
+		for _, alias := range lc.Aliases {
+			aliases[strings.ToLower(strings.TrimSpace(alias))] = name
+		}
+	}
+
+	Levels, levelAliases = levels, aliases
+	return nil
+}
+
+// sortedLevels returns the known levels from least to most severe.
+func sortedLevels() []*Level {
+	levels := slices.Collect(maps.Values(Levels))
+	slices.SortFunc(levels, func(a, b *Level) int {
+		return cmp.Or(cmp.Compare(a.Severity, b.Severity), cmp.Compare(a.Name, b.Name))
+	})
+	return levels
+}
+
+// getLevel returns the level of the entry. A numeric level, as bunyan and pino
+// write it, is turned into its string form, like "50", which is an alias. A
+// level raised by escalateLevel gives the level it was raised to. An entry
+// without a level gives "".
+func getLevel(lineData map[string]any) string {
+	switch level := lineData[lvlField].(type) {
+	case string:
+		return level
+	case float64:
+		return strconv.FormatFloat(level, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(level)
+	case json.Number:
+		return level.String()
+	case escalatedLevel:
+		return level.name
+	}
+	return ""
+}
+
+// lookupLevel finds the level by its name or an alias, ignoring case.
+func lookupLevel(level string) (*Level, bool) {
+	level = strings.ToLower(strings.TrimSpace(level))
+	if canonical, ok := levelAliases[level]; ok {
+		level = canonical
+	}
+	l, ok := Levels[level]
+	return l, ok
+}
+
+// canonicalLevel normalizes the level to its lowercase canonical name. Unknown
+// levels are only lowercased.
+func canonicalLevel(level string) string {
+	if l, ok := lookupLevel(level); ok {
+		return l.Name
+	}
+	return strings.ToLower(level)
+}
+
+// displayLevel returns the name the level is shown as, like WARN for warning.
+// Unknown levels are upper-cased.
+func displayLevel(level string) string {
+	if l, ok := lookupLevel(level); ok {
+		return l.Display
+	}
+	return strings.ToUpper(level)
+}
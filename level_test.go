@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"maps"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalLevel(t *testing.T) {
+	tests := map[string]string{
+		"INFO":        "info",
+		"Information": "info",
+		"WARNING":     "warn",
+		"warn":        "warn",
+		"ERR":         "error",
+		"Verbose":     "trace",
+		"CRITICAL":    "fatal",
+		"dpanic":      "dpanic",
+		"custom":      "custom",
+		"W":           "warn",
+		"e":           "error",
+		"TRACE":       "trace",
+		"Panic":       "panic",
+		"NOTICE":      "info",
+		"30":          "info",
+		"⚠️":          "warn",
+		" info ":      "info",
+	}
+
+	for level, want := range tests {
+		assert.Equal(t, want, canonicalLevel(level), "canonical name of %q", level)
+	}
+}
+
+// TestLevelToColorNameAliases checks that aliases share the color of their
+// canonical level rather than falling back to the info color.
+func TestLevelToColorNameAliases(t *testing.T) {
+	assert.Equal(t, ColorLevelWarn, LevelToColorName("WARNING"), "warning is a warning")
+	assert.Equal(t, ColorLevelDebug, LevelToColorName("Verbose"), "verbose is colored as debug")
+	assert.Equal(t, ColorLevelFatal, LevelToColorName("critical"), "critical is fatal")
+	assert.Equal(t, ColorLevelInfo, LevelToColorName("custom"), "unknown levels fall back to info")
+}
+
+// TestDisplayLevel checks that levels are shown by their normalized name,
+// whatever name the logger used.
+func TestDisplayLevel(t *testing.T) {
+	tests := map[string]string{
+		"warning":  "WARN",
+		"W":        "WARN",
+		"Err":      "ERROR",
+		"critical": "FATAL",
+		"verbose":  "TRACE",
+		"custom":   "CUSTOM",
+		"":         "",
+	}
+
+	for level, want := range tests {
+		assert.Equal(t, want, displayLevel(level), "display name of %q", level)
+	}
+}
+
+// TestLevelSeverity checks that the built-in levels are ordered from least to
+// most severe.
+func TestLevelSeverity(t *testing.T) {
+	for i := 1; i < len(builtinLevels); i++ {
+		assert.Less(t, builtinLevels[i-1].Severity, builtinLevels[i].Severity, "%s is less severe than %s", builtinLevels[i-1].Name, builtinLevels[i].Name)
+	}
+}
+
+// TestSetupLevels covers custom levels, their aliases and defaults, and
+// redefining a built-in level.
+func TestSetupLevels(t *testing.T) {
+	t.Cleanup(func() { _ = setupLevels(DefaultConfig()) })
+
+	c := DefaultConfig()
+	c.Levels = []LevelConfig{
+		{Name: "Audit", Aliases: []string{"AUD"}, Severity: 35},
+		{Name: "success", Display: "OK", Color: "level-info"},
+		{Name: "warn", Display: "WARNING", Severity: 40, Color: "level-warn"},
+		{Name: "error", Display: "ERR"},
+	}
+	require.NoError(t, setupLevels(c))
+
+	audit, ok := lookupLevel("aud")
+	require.True(t, ok, "alias of a custom level")
+	assert.Equal(t, &Level{"audit", "AUDIT", 35, "level-audit"}, audit, "custom level with defaults")
+	assert.Equal(t, ColorName("level-audit"), LevelToColorName("AUDIT"), "own palette key")
+
+	assert.Equal(t, "OK", displayLevel("success"), "custom display name")
+	assert.Equal(t, 30, Levels["success"].Severity, "severity defaults to info")
+	assert.Equal(t, "WARNING", displayLevel("w"), "redefined built-in keeps its aliases")
+
+	errLevel, _ := lookupLevel("error")
+	assert.Equal(t, &Level{"error", "ERR", 50, ColorLevelError}, errLevel, "redefined built-in keeps its severity and color")
+
+	c.Levels = []LevelConfig{{Name: " "}}
+	assert.Error(t, setupLevels(c), "a custom level needs a name")
+}
+
+// TestNumericLevel checks that the numeric levels bunyan and pino write are
+// shown, normalized, and filtered by --min-level like their string aliases.
+func TestNumericLevel(t *testing.T) {
+	orig := minLevel
+	t.Cleanup(func() { minLevel = orig })
+	minLevel = "warn"
+
+	var console, logfmt bytes.Buffer
+	cf := NewConsoleFormatter(&console, NewSugaredColorizer(&ColorOff{}), "ts", `{{index . "msg"}}`, []string{"level", "msg", "ts"}, &TimeDisplay{})
+	lf := NewLogfmtFormatter(&logfmt, "ts", `{{index . "msg"}}`, []string{"level", "msg", "ts"})
+
+	for _, line := range []string{
+		`{"level":50,"msg":"disk full","pid":7}`,
+		`{"level":30,"msg":"all good"}`,
+	} {
+		entries, err := parseLogEntries([]byte(line), "ts")
+		require.NoError(t, err, "parse %s", line)
+		for _, lineData := range entries {
+			if meetsMinLevel(lineData) {
+				lf.FormatEntry(maps.Clone(lineData))
+				cf.FormatEntry(lineData)
+			}
+		}
+	}
+
+	assert.Contains(t, console.String(), " ERROR  disk full", "numeric level shown by name")
+	assert.NotContains(t, console.String(), "all good", "info filtered out by --min-level")
+	assert.Equal(t, "level=error msg=\"disk full\" pid=7\n", logfmt.String(), "numeric level normalized")
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// otlpMarker is the key that every OTLP/JSON logs export starts with. It is
+// checked before decoding, so ordinary JSON log lines do not pay for a second
+// trip through the decoder.
+var otlpMarker = []byte(`"resourceLogs"`)
+
+// otlpLogsData is the part of an OTLP/JSON ExportLogsServiceRequest that logfmt
+// renders.
+type otlpLogsData struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpScopeLogs struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         otlpUint64     `json:"timeUnixNano"`
+	ObservedTimeUnixNano otlpUint64     `json:"observedTimeUnixNano"`
+	SeverityNumber       int            `json:"severityNumber"`
+	SeverityText         string         `json:"severityText"`
+	Body                 *otlpAnyValue  `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes"`
+	TraceID              string         `json:"traceId"`
+	SpanID               string         `json:"spanId"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string     `json:"stringValue"`
+	BoolValue   *bool       `json:"boolValue"`
+	IntValue    *otlpInt64  `json:"intValue"`
+	DoubleValue *float64    `json:"doubleValue"`
+	BytesValue  *string     `json:"bytesValue"`
+	ArrayValue  *otlpValues `json:"arrayValue"`
+	KvlistValue *otlpKVList `json:"kvlistValue"`
+}
+
+type otlpValues struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+type otlpKVList struct {
+	Values []otlpKeyValue `json:"values"`
+}
+
+// otlpUint64 is a 64-bit integer, which the OTLP/JSON encoding writes as a
+// decimal string. Some encoders write a bare number instead, so both are
+// accepted.
+type otlpUint64 uint64
+
+func (u *otlpUint64) UnmarshalJSON(bs []byte) error {
+	n, err := strconv.ParseUint(string(bytes.Trim(bs, `"`)), 10, 64)
+	if err != nil {
+		return err
+	}
+	*u = otlpUint64(n)
+	return nil
+}
+
+// otlpInt64 is the signed counterpart of otlpUint64.
+type otlpInt64 int64
+
+func (i *otlpInt64) UnmarshalJSON(bs []byte) error {
+	n, err := strconv.ParseInt(string(bytes.Trim(bs, `"`)), 10, 64)
+	if err != nil {
+		return err
+	}
+	*i = otlpInt64(n)
+	return nil
+}
+
+// value converts the OTLP value into the generic form that encoding/json would
+// have produced, except that integers stay int64 so large ones survive.
+func (v *otlpAnyValue) value() any {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	case v.IntValue != nil:
+		return int64(*v.IntValue)
+	case v.DoubleValue != nil:
+		return *v.DoubleValue
+	case v.BytesValue != nil:
+		return *v.BytesValue
+	case v.ArrayValue != nil:
+		vs := make([]any, len(v.ArrayValue.Values))
+		for i := range v.ArrayValue.Values {
+			vs[i] = v.ArrayValue.Values[i].value()
+		}
+		return vs
+	case v.KvlistValue != nil:
+		return otlpAttributes(v.KvlistValue.Values)
+	}
+	return nil
+}
+
+// otlpAttributes converts an OTLP key/value list into a generic map.
+func otlpAttributes(kvs []otlpKeyValue) map[string]any {
+	attrs := make(map[string]any, len(kvs))
+	for i := range kvs {
+		attrs[kvs[i].Key] = kvs[i].Value.value()
+	}
+	return attrs
+}
+
+// otlpSeverityText names the severity range that an OTLP severity number falls
+// into. It is used when a record has a number but no text.
+func otlpSeverityText(n int) string {
+	switch {
+	case n >= 21:
+		return "fatal"
+	case n >= 17:
+		return "error"
+	case n >= 13:
+		return "warn"
+	case n >= 9:
+		return "info"
+	case n >= 5:
+		return "debug"
+	case n >= 1:
+		return "trace"
+	}
+	return ""
+}
+
+// otlpTimestamp picks the event time of the record, falling back on the time
+// the collector observed it, as the OTLP spec recommends.
+func otlpTimestamp(rec *otlpLogRecord) time.Time {
+	ns := rec.TimeUnixNano
+	if ns == 0 {
+		ns = rec.ObservedTimeUnixNano
+	}
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(ns))
+}
+
+// parseOtlpLogLine parses an OpenTelemetry OTLP/JSON logs export, as written by
+// the collector's file exporter. A single line holds a batch of records, and
+// each one becomes its own entry. Record attributes are flattened into the
+// entry. Resource attributes are kept together under "resource", with
+// service.name copied to "service" to identify the source. The instrumentation
+// scope is reported as the logger.
+func parseOtlpLogLine(line []byte, tsField string) ([]map[string]any, error) {
+	if !bytes.Contains(line, otlpMarker) {
+		return nil, ErrUnparseable
+	}
+
+	var data otlpLogsData
+	if err := json.Unmarshal(line, &data); err != nil {
+		return nil, err
+	}
+
+	if data.ResourceLogs == nil {
+		return nil, ErrUnparseable
+	}
+
+	var entries []map[string]any
+	for _, rl := range data.ResourceLogs {
+		resource := otlpAttributes(rl.Resource.Attributes)
+		service, _ := getString(resource, "service.name")
+
+		for _, sl := range rl.ScopeLogs {
+			for i := range sl.LogRecords {
+				rec := &sl.LogRecords[i]
+
+				lineData := otlpAttributes(rec.Attributes)
+
+				level := rec.SeverityText
+				if level == "" {
+					level = otlpSeverityText(rec.SeverityNumber)
+				}
+
+				msg := ""
+				if rec.Body != nil {
+					switch body := rec.Body.value().(type) {
+					case string:
+						msg = body
+					case nil:
+					default:
+						bodyBytes, _ := json.Marshal(body)
+						msg = string(bodyBytes)
+					}
+				}
+
+				lineData[tsField] = otlpTimestamp(rec)
+				lineData[lvlField] = level
+				lineData[msgField] = msg
+
+				if sl.Scope.Name != "" {
+					lineData["logger"] = sl.Scope.Name
+				}
+				if rec.TraceID != "" {
+					lineData["trace_id"] = rec.TraceID
+				}
+				if rec.SpanID != "" {
+					lineData["span_id"] = rec.SpanID
+				}
+				if service != "" {
+					lineData["service"] = service
+				}
+				if len(resource) > 0 {
+					lineData["resource"] = resource
+				}
+
+				entries = append(entries, lineData)
+			}
+		}
+	}
+
+	// A batch without records would yield no entries and the line would
+	// disappear, so it is left to the other parsers instead.
+	if len(entries) == 0 {
+		return nil, ErrUnparseable
+	}
+
+	return entries, nil
+}
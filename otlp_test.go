@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const otlpLine = `{"resourceLogs":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"cart-svc"}},{"key":"k8s.pod.name","value":{"stringValue":"cart-7f9c"}}]},"scopeLogs":[{"scope":{"name":"cart/checkout"},"logRecords":[{"timeUnixNano":"1786630923117000000","severityNumber":9,"severityText":"INFO","body":{"stringValue":"order placed"},"attributes":[{"key":"order.id","value":{"intValue":"9007199254740993"}},{"key":"retry","value":{"boolValue":false}},{"key":"items","value":{"arrayValue":{"values":[{"stringValue":"a"},{"doubleValue":1.5}]}}}],"traceId":"5b8efff798038103d269b633813fc60c","spanId":"eee19b7ec3c1b174"},{"observedTimeUnixNano":1786630924000000000,"severityNumber":17,"body":{"kvlistValue":{"values":[{"key":"event","value":{"stringValue":"declined"}}]}}}]}]}]}`
+
+// TestParseOtlpLogLine checks that each record in the batch becomes an entry
+// with attributes flattened and the resource kept alongside.
+func TestParseOtlpLogLine(t *testing.T) {
+	entries, err := parseOtlpLogLine([]byte(otlpLine), "ts")
+	require.NoError(t, err, "OTLP line parses")
+	require.Len(t, entries, 2, "one entry per log record")
+
+	first := entries[0]
+	assert.Equal(t, time.Unix(0, 1786630923117000000), first["ts"], "timeUnixNano is the timestamp")
+	assert.Equal(t, "INFO", first["level"], "severityText is the level")
+	assert.Equal(t, "order placed", first["msg"], "string body is the message")
+	assert.Equal(t, int64(9007199254740993), first["order.id"], "int attributes keep full precision")
+	assert.Equal(t, false, first["retry"], "bool attribute flattened")
+	assert.Equal(t, []any{"a", 1.5}, first["items"], "array attribute flattened")
+	assert.Equal(t, "5b8efff798038103d269b633813fc60c", first["trace_id"], "trace ID mapped")
+	assert.Equal(t, "eee19b7ec3c1b174", first["span_id"], "span ID mapped")
+	assert.Equal(t, "cart/checkout", first["logger"], "scope name is the logger")
+	assert.Equal(t, "cart-svc", first["service"], "service.name is the source")
+	assert.Equal(t,
+		map[string]any{"service.name": "cart-svc", "k8s.pod.name": "cart-7f9c"},
+		first["resource"],
+		"resource attributes kept together",
+	)
+
+	second := entries[1]
+	assert.Equal(t, time.Unix(0, 1786630924000000000), second["ts"], "observed time used when time is unset")
+	assert.Equal(t, "error", second["level"], "level derived from severityNumber")
+	assert.Equal(t, `{"event":"declined"}`, second["msg"], "structured body rendered as JSON")
+	assert.NotContains(t, second, "trace_id", "no trace ID when the record has none")
+}
+
+// TestParseOtlpLogLineRejectsOtherJSON makes sure ordinary JSON log lines are
+// left to the regular JSON parser.
+func TestParseOtlpLogLineRejectsOtherJSON(t *testing.T) {
+	_, err := parseOtlpLogLine([]byte(`{"ts":"2026-08-13T14:22:03.117Z","level":"info","msg":"hi"}`), "ts")
+	assert.ErrorIs(t, err, ErrUnparseable, "plain JSON is not OTLP")
+
+	_, err = parseOtlpLogLine([]byte(`{"msg":"hi","resourceLogs":"not a batch"}`), "ts")
+	assert.Error(t, err, "marker alone is not enough")
+}
+
+// TestParseOtlpLogLineWithoutRecords makes sure a batch with no records is
+// left to the other parsers instead of being dropped.
+func TestParseOtlpLogLineWithoutRecords(t *testing.T) {
+	for _, line := range []string{
+		`{"resourceLogs":[]}`,
+		`{"resourceLogs":[{"scopeLogs":[]}]}`,
+		`{"resourceLogs":[{"scopeLogs":[{"logRecords":[]}]}]}`,
+	} {
+		entries, err := parseOtlpLogLine([]byte(line), "ts")
+		assert.ErrorIs(t, err, ErrUnparseable, "%s has no records", line)
+		assert.Empty(t, entries, "%s has no entries", line)
+
+		entries, err = parseLogEntries([]byte(line), "ts")
+		require.NoError(t, err, "%s still parses as plain JSON", line)
+		assert.Len(t, entries, 1, "%s is a single entry", line)
+	}
+}
+
+// TestParseLogEntries checks that batch lines expand and everything else still
+// yields a single entry.
+func TestParseLogEntries(t *testing.T) {
+	entries, err := parseLogEntries([]byte(otlpLine), "ts")
+	require.NoError(t, err, "OTLP line parses")
+	assert.Len(t, entries, 2, "OTLP batch expanded")
+
+	entries, err = parseLogEntries([]byte(`{"level":"info","msg":"hi"}`), "ts")
+	require.NoError(t, err, "JSON line parses")
+	require.Len(t, entries, 1, "single JSON entry")
+	assert.Equal(t, "hi", entries[0]["msg"], "JSON entry passed through")
+
+	_, err = parseLogEntries([]byte("just a plain line"), "ts")
+	assert.ErrorIs(t, err, ErrUnparseable, "plain text is unparseable")
+}
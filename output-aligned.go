@@ -0,0 +1,346 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+const (
+	// alignedMinWidth is the narrowest the message and trailing data are
+	// squeezed into. Below this, lines are allowed to run past the edge of
+	// the terminal instead.
+	alignedMinWidth = 20
+
+	// alignedLoggerMax and alignedCallerMax are the widest the logger and
+	// caller columns grow. Longer values are shortened from the left, which
+	// keeps the most specific part, like the file name and line.
+	alignedLoggerMax = 24
+	alignedCallerMax = 32
+)
+
+// AlignedFormatter lays log entries out in aligned columns: the timestamp, the
+// level, the logger, and the caller, followed by the message and the trailing
+// data. The timestamp and level columns start as wide as the time format and
+// level names take. The logger and caller columns take no room until one is
+// seen, and then as much as the longest shown. A value wider than its column,
+// like a timestamp with more digits, widens it, so the message starts at the
+// same offset on every line once the columns have settled. The
+// message and trailing data are wrapped to fit the width of the terminal, with
+// continuation lines indented to the message column, or truncated to a single
+// line.
+type AlignedFormatter struct {
+	out        io.Writer
+	c          *SugaredColorizer
+	tsField    string
+	msgFormat  string
+	trimFields []string
+	truncate   bool
+	width      *atomic.Int64
+	times      *TimeDisplay
+	colWidths  [4]int
+}
+
+// NewAlignedFormatter builds the aligned formatter. The width is read for
+// every line, so it may be updated as the terminal is resized. A width of 0
+// means unlimited, which only aligns.
+func NewAlignedFormatter(
+	out io.Writer,
+	c *SugaredColorizer,
+	tsField, msgFormat string,
+	trimFields []string,
+	truncate bool,
+	width *atomic.Int64,
+	times *TimeDisplay,
+) *AlignedFormatter {
+	return &AlignedFormatter{
+		out:        out,
+		c:          c,
+		tsField:    tsField,
+		msgFormat:  msgFormat,
+		trimFields: trimFields,
+		truncate:   truncate,
+		width:      width,
+		times:      times,
+		colWidths:  [4]int{times.MinWidth(), alignedLevelWidth()},
+	}
+}
+
+// alignedLevelWidth returns the width of the widest level name, with room for
+// the mark of an escalated level when levels may be escalated.
+func alignedLevelWidth() int {
+	width := 0
+	for _, l := range Levels {
+		width = max(width, utf8.RuneCountInString(l.Display))
+	}
+	if escalateWorries {
+		width++
+	}
+	return width
+}
+
+// alignedSegment is a run of text to lay out, along with how to colorize it.
+// Render colorizes the part of the text from one rune offset to another, so a
+// segment split across lines can be colorized as it would be as a whole.
+type alignedSegment struct {
+	text   []rune
+	render func(from, to int) string
+}
+
+// newTextSegment builds a segment that colorizes each part of the text on its
+// own.
+func newTextSegment(text string, render func(string) string) alignedSegment {
+	rs := []rune(text)
+	return alignedSegment{
+		text: rs,
+		render: func(from, to int) string {
+			return render(string(rs[from:to]))
+		},
+	}
+}
+
+// newDataSegment builds a segment for the trailing JSON data, which is
+// colorized using the tokens of the whole of it.
+func newDataSegment(c *SugaredColorizer, data string, valueColors map[string]ColorName) alignedSegment {
+	tokens := tokenizeData([]byte(data), valueColors)
+
+	offsets := make([]int, 0, len(data)+1)
+	for i := range data {
+		offsets = append(offsets, i)
+	}
+	offsets = append(offsets, len(data))
+
+	return alignedSegment{
+		text: []rune(data),
+		render: func(from, to int) string {
+			return c.C(ColorData, colorizeDataTokens(c, data, tokens, offsets[from], offsets[to]))
+		},
+	}
+}
+
+// FormatRaw outputs the line wrapped or truncated to the full width.
+func (af *AlignedFormatter) FormatRaw(line string) {
+	af.writeLines("", 0, []alignedSegment{
+		newTextSegment(line, func(s string) string {
+			if highlightWorriesIn("raw") {
+				s = HighlightWorries(af.c, s)
+			}
+			return af.c.C(ColorNormal, s)
+		}),
+	})
+}
+
+// FormatEntry outputs the entry in columns, followed by the extracted fields.
+func (af *AlignedFormatter) FormatEntry(lineData map[string]any) {
+	level := getLevel(lineData)
+	level = displayLevel(level)
+	levelColorName := LevelToColorName(level)
+	level = markEscalated(lineData, level)
+	logger, _ := getString(lineData, "logger")
+	caller, _ := getString(lineData, callerField)
+
+	columns := [4]struct {
+		text  string
+		color ColorName
+		max   int
+	}{
+		{af.times.Format(lineData, af.tsField), ColorDateTime, 0},
+		{level, levelColorName, 0},
+		{logger, ColorLogger, alignedLoggerMax},
+		{caller, ColorCaller, alignedCallerMax},
+	}
+
+	msg := formatMessage(lineData, af.msgFormat)
+	extracts := getExtracts(lineData)
+
+	for _, field := range af.trimFields {
+		delete(lineData, field)
+	}
+	delete(lineData, "logger")
+	delete(lineData, callerField)
+
+	valueColors := identityColors(lineData)
+	applyRenderRules(lineData, valueColors)
+
+	prefix := &strings.Builder{}
+	indent := 0
+	for i, col := range columns {
+		// A logger or caller column is left out until there is one to show
+		if col.text == "" && af.colWidths[i] == 0 {
+			continue
+		}
+
+		text := shortenLeft(col.text, col.max)
+		af.colWidths[i] = max(af.colWidths[i], col.max, utf8.RuneCountInString(text))
+
+		pad := af.colWidths[i] - utf8.RuneCountInString(text)
+		if text != "" {
+			prefix.WriteString(af.c.C(col.color, text))
+		}
+		prefix.WriteString(strings.Repeat(" ", pad+1))
+		indent += af.colWidths[i] + 1
+	}
+
+	segments := []alignedSegment{
+		newTextSegment(msg, func(s string) string {
+			if highlightWorriesIn("message") {
+				s = HighlightWorries(af.c, s)
+			}
+			return af.c.C(ColorMessage, s)
+		}),
+	}
+
+	lineDataBytes := marshalTrailingData(lineData)
+	expanded := lineDataBytes != nil && expandData(lineDataBytes)
+	if lineDataBytes != nil && !expanded {
+		segments = append(segments, newDataSegment(af.c, " "+string(lineDataBytes), valueColors))
+	}
+
+	af.writeLines(prefix.String(), indent, segments)
+
+	if expanded {
+		outputDataTree(af.out, af.c, lineDataBytes, valueColors)
+	}
+	outputExtracts(af.out, af.c, extracts)
+}
+
+func (af *AlignedFormatter) Close() error {
+	return nil
+}
+
+// writeLines lays out the segments to the right of the prefix, within the
+// width remaining after indent columns, and outputs them. When the prefix
+// leaves less than alignedMinWidth, the segments start on the line below it,
+// indented less, so no line is wider than the terminal but the prefix itself.
+func (af *AlignedFormatter) writeLines(prefix string, indent int, segments []alignedSegment) {
+	var text []rune
+	for _, seg := range segments {
+		text = append(text, seg.text...)
+	}
+
+	avail := 0
+	if width := int(af.width.Load()); width > 0 {
+		if limit := max(width-alignedMinWidth, 0); indent > limit {
+			prefix = strings.TrimRight(prefix, " ") + "\n" + strings.Repeat(" ", limit)
+			indent = limit
+		}
+		avail = width - indent
+	}
+
+	var ranges [][2]int
+	ellipsis := false
+	if af.truncate {
+		ranges, ellipsis = truncateRange(text, avail)
+	} else {
+		ranges = wrapRanges(text, avail)
+	}
+
+	sb := &strings.Builder{}
+	for i, r := range ranges {
+		if i == 0 {
+			sb.WriteString(prefix)
+		} else {
+			sb.WriteString(strings.Repeat(" ", indent))
+		}
+
+		start := 0
+		for _, seg := range segments {
+			end := start + len(seg.text)
+			from, to := max(r[0], start), min(r[1], end)
+			if from < to {
+				sb.WriteString(seg.render(from-start, to-start))
+			}
+			start = end
+		}
+
+		if ellipsis {
+			sb.WriteString(af.c.C(ColorNormal, "…"))
+		}
+		sb.WriteString("\n")
+	}
+
+	_, _ = fmt.Fprint(af.out, sb.String())
+}
+
+// wrapRanges splits text into lines of at most width runes, breaking after a
+// space where there is one in the second half of the line, and always breaking
+// at a newline. It returns the start and end of each line. A width of 0 breaks
+// only at newlines.
+func wrapRanges(text []rune, width int) [][2]int {
+	ranges := [][2]int{}
+	start := 0
+	for {
+		limit := len(text)
+		if width > 0 && start+width < limit {
+			limit = start + width
+		}
+
+		if nl := indexRune(text[start:limit], '\n'); nl >= 0 {
+			ranges = append(ranges, [2]int{start, start + nl})
+			start += nl + 1
+			continue
+		}
+
+		if limit == len(text) {
+			ranges = append(ranges, [2]int{start, limit})
+			return ranges
+		}
+
+		brk := limit
+		for i := limit; i > start+width/2; i-- {
+			if text[i] == ' ' {
+				brk = i
+				break
+			}
+		}
+
+		ranges = append(ranges, [2]int{start, brk})
+		start = brk
+		if text[start] == ' ' {
+			start++
+		}
+	}
+}
+
+// truncateRange returns the part of text that fits on one line of width runes,
+// stopping at the first newline, and whether anything was cut off. When text
+// is cut, room is left for an ellipsis. A width of 0 only stops at a newline.
+func truncateRange(text []rune, width int) ([][2]int, bool) {
+	end := len(text)
+	if nl := indexRune(text, '\n'); nl >= 0 {
+		end = nl
+	}
+	if width > 0 && end > width {
+		end = width
+	}
+
+	if end < len(text) {
+		if width > 0 && end == width {
+			end--
+		}
+		return [][2]int{{0, end}}, true
+	}
+	return [][2]int{{0, end}}, false
+}
+
+// shortenLeft shortens s to at most n runes by replacing its start with an
+// ellipsis. An n of 0 leaves s as it is.
+func shortenLeft(s string, n int) string {
+	rs := []rune(s)
+	if n == 0 || len(rs) <= n {
+		return s
+	}
+	return "…" + string(rs[len(rs)-n+1:])
+}
+
+// indexRune returns the index of the first r in rs, or -1.
+func indexRune(rs []rune, r rune) int {
+	for i, c := range rs {
+		if c == r {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestAlignedFormatter builds an uncolorized aligned formatter of the given
+// width.
+func newTestAlignedFormatter(out *bytes.Buffer, width int64, truncate bool) *AlignedFormatter {
+	w := &atomic.Int64{}
+	w.Store(width)
+	return NewAlignedFormatter(out, NewSugaredColorizer(&ColorOff{}), "ts", `{{with index . "msg"}}{{.}}{{end}}`, []string{"level", "msg", "stacktrace", "error", "ts"}, truncate, w, &TimeDisplay{})
+}
+
+// TestAlignedFormatter checks that the columns line up across entries, whatever
+// their values, and that extracted fields are still shown below.
+func TestAlignedFormatter(t *testing.T) {
+	out := &bytes.Buffer{}
+	af := newTestAlignedFormatter(out, 0, false)
+
+	ts := time.Date(2026, 8, 13, 14, 22, 9, 482_000_000, time.UTC)
+	af.FormatEntry(map[string]any{"ts": ts, "level": "info", "logger": "api", "caller": "api/server.go:88", "msg": "listening"})
+	af.FormatEntry(map[string]any{"ts": ts, "level": "error", "logger": "proxy", "caller": "proxy/client.go:240", "msg": "request failed", "error": "refused", "attempt": 2.0})
+
+	af.FormatEntry(map[string]any{"ts": ts, "level": "warn", "msg": "no logger"})
+	af.FormatEntry(map[string]any{"ts": ts, "level": "info", "logger": "a.very.long.logger.name.indeed", "msg": "long logger"})
+
+	assert.Equal(t, ""+
+		"2026-08-13T14:22:09.482Z INFO   api                      api/server.go:88                 listening\n"+
+		"2026-08-13T14:22:09.482Z ERROR  proxy                    proxy/client.go:240              request failed {\"attempt\":2}\n"+
+		"    refused\n"+
+		"2026-08-13T14:22:09.482Z WARN                                                             no logger\n"+
+		"2026-08-13T14:22:09.482Z INFO   …long.logger.name.indeed                                  long logger\n",
+		out.String(), "columns aligned from the first line, extracted field below")
+}
+
+// TestAlignedFormatterNoLoggerOrCaller checks that the logger and caller
+// columns take no room until an entry has one.
+func TestAlignedFormatterNoLoggerOrCaller(t *testing.T) {
+	out := &bytes.Buffer{}
+	af := newTestAlignedFormatter(out, 0, false)
+
+	ts := time.Date(2026, 8, 13, 14, 22, 9, 482_000_000, time.UTC)
+	af.FormatEntry(map[string]any{"ts": ts, "level": "info", "msg": "starting"})
+	af.FormatEntry(map[string]any{"level": "warn", "msg": "no time"})
+	af.FormatEntry(map[string]any{"ts": ts, "level": "info", "caller": "main.go:12", "msg": "listening"})
+
+	assert.Equal(t, ""+
+		"2026-08-13T14:22:09.482Z INFO   starting\n"+
+		"0000-00-00T00:00:00.000000-00:00 WARN   no time\n"+
+		"2026-08-13T14:22:09.482Z         INFO   main.go:12                       listening\n",
+		out.String(), "columns added as they are needed")
+}
+
+// TestAlignedFormatterWrap checks that long messages wrap at spaces, with the
+// continuation indented to the message column.
+func TestAlignedFormatterWrap(t *testing.T) {
+	out := &bytes.Buffer{}
+	af := newTestAlignedFormatter(out, 56, false)
+
+	ts := time.Date(2026, 8, 13, 14, 22, 9, 482_000_000, time.UTC)
+	af.FormatEntry(map[string]any{"ts": ts, "level": "info", "msg": "the quick brown fox jumps over the lazy dog"})
+
+	assert.Equal(t, ""+
+		"2026-08-13T14:22:09.482Z INFO   the quick brown fox\n"+
+		"                                jumps over the lazy dog\n",
+		out.String(), "wrapped to the message column")
+}
+
+// TestAlignedFormatterNarrow checks that when the columns leave too little of
+// the width for the message, it starts below them, and stays within the width.
+func TestAlignedFormatterNarrow(t *testing.T) {
+	out := &bytes.Buffer{}
+	af := newTestAlignedFormatter(out, 40, false)
+
+	ts := time.Date(2026, 8, 13, 14, 22, 9, 482_000_000, time.UTC)
+	af.FormatEntry(map[string]any{"ts": ts, "level": "info", "msg": "the quick brown fox jumps over the lazy dog"})
+
+	assert.Equal(t, ""+
+		"2026-08-13T14:22:09.482Z INFO\n"+
+		"                    the quick brown fox\n"+
+		"                    jumps over the lazy\n"+
+		"                    dog\n",
+		out.String(), "message below the columns")
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		assert.LessOrEqual(t, utf8.RuneCountInString(line), 40, "%q fits", line)
+	}
+}
+
+// TestAlignedFormatterTruncate checks that long messages are cut off with an
+// ellipsis in truncate mode.
+func TestAlignedFormatterTruncate(t *testing.T) {
+	out := &bytes.Buffer{}
+	af := newTestAlignedFormatter(out, 52, true)
+
+	ts := time.Date(2026, 8, 13, 14, 22, 9, 482_000_000, time.UTC)
+	af.FormatEntry(map[string]any{"ts": ts, "level": "info", "msg": "the quick brown fox jumps over the lazy dog"})
+	af.FormatRaw("short raw line")
+
+	assert.Equal(t, "2026-08-13T14:22:09.482Z INFO   the quick brown fox…\nshort raw line\n", out.String(), "truncated with an ellipsis")
+}
+
+// TestWrapRanges covers breaking at spaces, at newlines, and mid-word when
+// there is no space to break at.
+func TestWrapRanges(t *testing.T) {
+	wrap := func(s string, width int) []string {
+		rs := []rune(s)
+		var lines []string
+		for _, r := range wrapRanges(rs, width) {
+			lines = append(lines, string(rs[r[0]:r[1]]))
+		}
+		return lines
+	}
+
+	assert.Equal(t, []string{"aaa bbb", "ccc"}, wrap("aaa bbb ccc", 8), "break at a space")
+	assert.Equal(t, []string{"aaaaaaaa", "bbbb"}, wrap("aaaaaaaabbbb", 8), "break mid-word")
+	assert.Equal(t, []string{"one", "two"}, wrap("one\ntwo", 0), "break at a newline")
+	assert.Equal(t, []string{""}, wrap("", 8), "empty")
+}
+
+// TestShortenLeft checks that long values keep their end.
+func TestShortenLeft(t *testing.T) {
+	assert.Equal(t, "…y/client.go:240", shortenLeft("internal/proxy/client.go:240", 16), "shortened")
+	assert.Equal(t, "client.go:240", shortenLeft("client.go:240", 16), "short enough")
+	assert.Equal(t, "client.go:240", shortenLeft("client.go:240", 0), "no limit")
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// CSVFormatter writes the selected columns of each log entry as a row of CSV,
+// or TSV, preceded by a header row naming the columns. Cells holding the
+// separator, quotes, or newlines, like stacktraces, are quoted as RFC 4180
+// describes, which spreadsheets understand for both. It never colorizes.
+type CSVFormatter struct {
+	w          *csv.Writer
+	tsField    string
+	msgFormat  string
+	columns    []string
+	headerDone bool
+}
+
+// NewCSVFormatter builds the CSV formatter, separating cells with comma. The
+// columns name the fields to write, where ts, level, and msg stand for the
+// timestamp, level, and message fields, whatever they are named in the input,
+// and level_inferred for whether the level was escalated.
+func NewCSVFormatter(
+	out io.Writer,
+	comma rune,
+	tsField, msgFormat string,
+	columns []string,
+) *CSVFormatter {
+	w := csv.NewWriter(out)
+	w.Comma = comma
+	return &CSVFormatter{
+		w:         w,
+		tsField:   tsField,
+		msgFormat: msgFormat,
+		columns:   columns,
+	}
+}
+
+// FormatRaw outputs the line in the msg column, leaving the others empty.
+func (cf *CSVFormatter) FormatRaw(line string) {
+	row := make([]string, len(cf.columns))
+	for i, column := range cf.columns {
+		if column == "msg" {
+			row[i] = line
+		}
+	}
+	cf.writeRow(row)
+}
+
+// FormatEntry outputs the columns of the entry. A column the entry does not
+// have is left empty.
+func (cf *CSVFormatter) FormatEntry(lineData map[string]any) {
+	row := make([]string, len(cf.columns))
+	for i, column := range cf.columns {
+		switch column {
+		case "ts":
+			if ts, err := getTime(lineData, cf.tsField); err == nil && !ts.IsZero() {
+				row[i] = ts.Format(time.RFC3339Nano)
+			} else if text, err := getString(lineData, cf.tsField); err == nil {
+				row[i] = text
+			}
+		case "level":
+			row[i] = canonicalLevel(getLevel(lineData))
+		case levelInferredKey:
+			row[i] = strconv.FormatBool(levelInferred(lineData))
+		case "msg":
+			row[i] = formatMessage(lineData, cf.msgFormat)
+		default:
+			if v, ok := lineData[column]; ok && v != nil {
+				row[i] = fieldString(v)
+			}
+		}
+	}
+	cf.writeRow(row)
+}
+
+// writeRow writes the header first, if it has not been written yet, and then
+// the row. Each row is flushed, so output keeps up with a followed log.
+func (cf *CSVFormatter) writeRow(row []string) {
+	if !cf.headerDone {
+		_ = cf.w.Write(cf.columns)
+		cf.headerDone = true
+	}
+	_ = cf.w.Write(row)
+	cf.w.Flush()
+}
+
+// Close writes the header, if no rows were written, so the output always has
+// one.
+func (cf *CSVFormatter) Close() error {
+	if !cf.headerDone {
+		_ = cf.w.Write(cf.columns)
+		cf.headerDone = true
+		cf.w.Flush()
+	}
+	return cf.w.Error()
+}
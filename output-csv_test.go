@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCSVFormatter checks the header, the selected columns, and that cells
+// with newlines survive a round trip through a CSV reader.
+func TestCSVFormatter(t *testing.T) {
+	out := &bytes.Buffer{}
+	cf := NewCSVFormatter(out, ',', "ts", `{{with index . "msg"}}{{.}}{{end}}`, []string{"ts", "level", "msg", "upstream", "attempt", "stacktrace"})
+
+	cf.FormatEntry(map[string]any{
+		"ts":         time.Date(2026, 8, 13, 14, 22, 9, 482_000_000, time.UTC),
+		"level":      "WARN",
+		"msg":        "upstream returned 503, retrying",
+		"upstream":   "cart-svc",
+		"attempt":    2.0,
+		"stacktrace": "proxy.(*Client).Do\n\tproxy/client.go:240",
+	})
+	cf.FormatEntry(map[string]any{"level": "info", "msg": `said "hi"`})
+	cf.FormatRaw("Starting up, plain line")
+
+	rows, err := csv.NewReader(out).ReadAll()
+	require.NoError(t, err, "output is valid CSV")
+
+	assert.Equal(t, [][]string{
+		{"ts", "level", "msg", "upstream", "attempt", "stacktrace"},
+		{"2026-08-13T14:22:09.482Z", "warn", "upstream returned 503, retrying", "cart-svc", "2", "proxy.(*Client).Do\n\tproxy/client.go:240"},
+		{"", "info", `said "hi"`, "", "", ""},
+		{"", "", "Starting up, plain line", "", "", ""},
+	}, rows, "header and rows")
+}
+
+// TestCSVFormatterTSV checks the tab separator and that the header is written
+// only once.
+func TestCSVFormatterTSV(t *testing.T) {
+	out := &bytes.Buffer{}
+	cf := NewCSVFormatter(out, '\t', "ts", `{{with index . "msg"}}{{.}}{{end}}`, []string{"level", "msg"})
+
+	cf.FormatEntry(map[string]any{"level": "info", "msg": "one, two"})
+	cf.FormatEntry(map[string]any{"level": "error", "msg": "tab\there"})
+
+	assert.Equal(t, "level\tmsg\ninfo\tone, two\nerror\t\"tab\there\"\n", out.String(), "tab separated")
+}
+
+// TestCSVFormatterNoMessage checks that, with the message format
+// setupFormatter builds, an entry without a message leaves the msg column
+// empty, in CSV and TSV alike.
+func TestCSVFormatterNoMessage(t *testing.T) {
+	origFormat, origColumns := outputFormat, columns
+	t.Cleanup(func() { outputFormat, columns = origFormat, origColumns })
+	columns = []string{"ts", "level", "msg"}
+
+	for format, want := range map[string]string{
+		"csv": "ts,level,msg\n,info,\n",
+		"tsv": "ts\tlevel\tmsg\n\tinfo\t\n",
+	} {
+		outputFormat = format
+
+		out := &bytes.Buffer{}
+		setupFormatter(out, &TimeDisplay{}).FormatEntry(map[string]any{"level": "info", "foo": 1.0})
+
+		assert.Equal(t, want, out.String(), "empty %s message, not <no value>", format)
+	}
+}
+
+// TestCSVFormatterEmpty checks that the header is written even when there are
+// no rows.
+func TestCSVFormatterEmpty(t *testing.T) {
+	out := &bytes.Buffer{}
+	cf := NewCSVFormatter(out, ',', "ts", `{{with index . "msg"}}{{.}}{{end}}`, []string{"ts", "msg"})
+
+	require.NoError(t, cf.Close(), "close flushes")
+	assert.Equal(t, "ts,msg\n", out.String(), "header only")
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"html"
+	gc "image/color"
+	"io"
+	"sort"
+	"strings"
+)
+
+// The HTML colorizer marks colored text with these private use characters
+// instead of writing tags directly, because the console layout colorizes text
+// that is already colorized, like worry words inside the message. The marks
+// survive that nesting and are turned into properly nested spans, with
+// everything else escaped, once the whole line has been rendered.
+const (
+	htmlMarkOpen  = '\uE000'
+	htmlMarkName  = '\uE001'
+	htmlMarkClose = '\uE002'
+)
+
+// htmlMarkReplacer replaces the marks where they are found in the log itself,
+// so they cannot be taken for the ones ColorHTML writes.
+var htmlMarkReplacer = strings.NewReplacer(
+	string(htmlMarkOpen), "\uFFFD",
+	string(htmlMarkName), "\uFFFD",
+	string(htmlMarkClose), "\uFFFD",
+)
+
+// replaceHTMLMarks replaces the marks in the strings of a value decoded from
+// JSON, and in the keys of its objects, modifying it in place where it can.
+func replaceHTMLMarks(v any) any {
+	switch v := v.(type) {
+	case string:
+		return htmlMarkReplacer.Replace(v)
+	case map[string]any:
+		for k, e := range v {
+			clean := htmlMarkReplacer.Replace(k)
+			if clean != k {
+				delete(v, k)
+			}
+			v[clean] = replaceHTMLMarks(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = replaceHTMLMarks(e)
+		}
+	}
+	return v
+}
+
+// ColorHTML is the colorizer used for HTML output. See htmlMarkOpen.
+type ColorHTML struct{}
+
+func (ch *ColorHTML) C(c ColorName, v ...any) string {
+	return string(htmlMarkOpen) + string(c) + string(htmlMarkName) + fmt.Sprint(v...) + string(htmlMarkClose)
+}
+
+// htmlColorClass names the CSS class used for the color name.
+func htmlColorClass(c ColorName) string {
+	return "c-" + strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(string(c)))
+}
+
+// markedToHTML escapes the text rendered with ColorHTML and turns its marks
+// into spans.
+func markedToHTML(s string) string {
+	sb := &strings.Builder{}
+	for len(s) > 0 {
+		i := strings.IndexAny(s, string([]rune{htmlMarkOpen, htmlMarkClose}))
+		if i < 0 {
+			sb.WriteString(html.EscapeString(s))
+			break
+		}
+
+		sb.WriteString(html.EscapeString(s[:i]))
+		s = s[i:]
+
+		if strings.HasPrefix(s, string(htmlMarkClose)) {
+			sb.WriteString("</span>")
+			s = s[len(string(htmlMarkClose)):]
+			continue
+		}
+
+		s = s[len(string(htmlMarkOpen)):]
+		name, rest, _ := strings.Cut(s, string(htmlMarkName))
+		sb.WriteString(`<span class="` + htmlColorClass(ColorName(name)) + `">`)
+		s = rest
+	}
+	return sb.String()
+}
+
+// HTMLFormatter writes a self-contained HTML report of the log, colorized with
+// the palette through CSS embedded in the page. Each line gets an anchor to link
+// to, extracted fields are collapsible, and a sidebar filters lines by level.
+type HTMLFormatter struct {
+	out     io.Writer
+	palette Palette
+	buf     *bytes.Buffer
+	console *ConsoleFormatter
+	lineNo  int
+	started bool
+}
+
+// NewHTMLFormatter builds the HTML formatter. Entries are laid out as the
+// console formatter lays them out, colorized with the palette unless colorOn is
+// false.
+func NewHTMLFormatter(
+	out io.Writer,
+	palette Palette,
+	colorOn bool,
+	tsField, msgFormat string,
+	trimFields []string,
+	times *TimeDisplay,
+) *HTMLFormatter {
+	var pc PlainColorizer = &ColorOff{}
+	if colorOn {
+		pc = &ColorHTML{}
+	}
+
+	buf := &bytes.Buffer{}
+	return &HTMLFormatter{
+		out:     out,
+		palette: palette,
+		buf:     buf,
+		console: NewConsoleFormatter(buf, NewSugaredColorizer(pc), tsField, msgFormat, trimFields, times),
+	}
+}
+
+// FormatRaw outputs the line with the level "raw".
+func (hf *HTMLFormatter) FormatRaw(line string) {
+	hf.buf.Reset()
+	hf.console.FormatRaw(htmlMarkReplacer.Replace(line))
+	hf.writeEntry("raw", nil)
+}
+
+// FormatEntry outputs the entry with its first line shown and the extracted
+// fields below it in a collapsible block.
+func (hf *HTMLFormatter) FormatEntry(lineData map[string]any) {
+	level := getLevel(lineData)
+	level = canonicalLevel(level)
+	if level == "" {
+		level = "none"
+	}
+
+	var extracted []string
+	for _, extractField := range extractFields {
+		if ex, err := getString(lineData, extractField); err == nil && ex != "" {
+			extracted = append(extracted, extractField)
+		}
+	}
+
+	replaceHTMLMarks(lineData)
+
+	hf.buf.Reset()
+	hf.console.FormatEntry(lineData)
+	hf.writeEntry(level, extracted)
+}
+
+// writeEntry writes the entry the console formatter rendered into the buffer.
+func (hf *HTMLFormatter) writeEntry(level string, extracted []string) {
+	hf.start()
+	hf.lineNo++
+
+	first, rest, _ := strings.Cut(strings.TrimSuffix(hf.buf.String(), "\n"), "\n")
+
+	sb := &strings.Builder{}
+	_, _ = fmt.Fprintf(sb, `<div class="entry" id="L%d" data-level="%s">`, hf.lineNo, html.EscapeString(level))
+	_, _ = fmt.Fprintf(sb, `<a class="ln" href="#L%d">%d</a>`, hf.lineNo, hf.lineNo)
+	sb.WriteString(`<span class="text">` + markedToHTML(first) + `</span>`)
+	if rest != "" {
+		summary := strings.Join(extracted, ", ")
+		if summary == "" {
+			summary = "details"
+		}
+		sb.WriteString(`<details><summary>` + html.EscapeString(summary) + `</summary>`)
+		sb.WriteString(`<pre>` + markedToHTML(rest) + `</pre></details>`)
+	}
+	sb.WriteString("</div>\n")
+
+	_, _ = io.WriteString(hf.out, sb.String())
+}
+
+// start writes the head of the page, unless that has been done already.
+func (hf *HTMLFormatter) start() {
+	if hf.started {
+		return
+	}
+	hf.started = true
+
+	names := make([]string, 0, len(hf.palette))
+	for name := range hf.palette {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+
+	// Palettes for light backgrounds, with dark normal text, get a light page.
+	normal := "#dddddd"
+	page, sidebar, target := "#1e1e1e", "#2a2a2a", "#3a3a20"
+	if c := hf.palette[ColorNormal].FG; c != nil {
+		normal = cssColor(c)
+		if !isLightColor(c) {
+			page, sidebar, target = "#fdfdfd", "#eeeeee", "#fff5c0"
+		}
+	}
+
+	css := &strings.Builder{}
+	for _, name := range names {
+		_, _ = fmt.Fprintf(css, ".%s { %s }\n", htmlColorClass(ColorName(name)), cssStyle(hf.palette[ColorName(name)], normal, page))
+	}
+
+	_, _ = fmt.Fprintf(hf.out, htmlHead, page, normal, sidebar, target, css.String())
+}
+
+// Close finishes the page.
+func (hf *HTMLFormatter) Close() error {
+	hf.start()
+	_, err := io.WriteString(hf.out, htmlFoot)
+	return err
+}
+
+const htmlHead = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>logfmt report</title>
+<style>
+body { margin: 0; background: %s; color: %s; font-family: ui-monospace, Menlo, Consolas, monospace; font-size: 13px; }
+#levels { position: fixed; top: 0; left: 0; bottom: 0; width: 10em; padding: 1em; background: %s; overflow-y: auto; }
+#levels label { display: block; cursor: pointer; }
+#entries { margin-left: 12em; padding: 1em; }
+.entry { white-space: pre-wrap; word-break: break-all; }
+.entry:target { background: %s; }
+.ln { display: inline-block; width: 5em; margin-right: 1em; color: #666666; text-align: right; text-decoration: none; user-select: none; }
+details { margin-left: 6em; }
+summary { color: #888888; cursor: pointer; }
+pre { margin: 0; font: inherit; }
+%s</style>
+</head>
+<body>
+<nav id="levels"><strong>Levels</strong></nav>
+<main id="entries">
+`
+
+const htmlFoot = `</main>
+<script>
+(function () {
+  var order = ["trace", "debug", "info", "warn", "error", "dpanic", "panic", "fatal"];
+  var entries = document.querySelectorAll(".entry");
+  var counts = {};
+  entries.forEach(function (e) {
+    counts[e.dataset.level] = (counts[e.dataset.level] || 0) + 1;
+  });
+
+  var rank = function (level) {
+    var i = order.indexOf(level);
+    return i < 0 ? order.length : i;
+  };
+  var levels = Object.keys(counts).sort(function (a, b) {
+    return rank(a) - rank(b) || a.localeCompare(b);
+  });
+
+  var hidden = {};
+  var nav = document.getElementById("levels");
+  levels.forEach(function (level) {
+    var box = document.createElement("input");
+    box.type = "checkbox";
+    box.checked = true;
+    box.addEventListener("change", function () {
+      hidden[level] = !box.checked;
+      entries.forEach(function (e) {
+        e.style.display = hidden[e.dataset.level] ? "none" : "";
+      });
+    });
+
+    var label = document.createElement("label");
+    label.appendChild(box);
+    label.appendChild(document.createTextNode(" " + level + " (" + counts[level] + ")"));
+    nav.appendChild(label);
+  });
+})();
+</script>
+</body>
+</html>
+`
+
+// cssStyle formats the style as CSS declarations. Reversed text swaps the
+// foreground and background, taking the normal text and page colors for either
+// one that is not set.
+func cssStyle(s Style, normal, page string) string {
+	var fg, bg string
+	if s.FG != nil {
+		fg = cssColor(s.FG)
+	}
+	if s.BG != nil {
+		bg = cssColor(s.BG)
+	}
+	if s.Reverse {
+		fg, bg = cmp.Or(bg, page), cmp.Or(fg, normal)
+	}
+
+	var decls []string
+	if fg != "" {
+		decls = append(decls, "color: "+fg+";")
+	}
+	if bg != "" {
+		decls = append(decls, "background-color: "+bg+";")
+	}
+	if s.Bold {
+		decls = append(decls, "font-weight: bold;")
+	}
+	if s.Dim {
+		decls = append(decls, "opacity: 0.6;")
+	}
+	if s.Italic {
+		decls = append(decls, "font-style: italic;")
+	}
+	if s.Underline {
+		decls = append(decls, "text-decoration: underline;")
+	}
+	return strings.Join(decls, " ")
+}
+
+// cssColor formats the color as a CSS hex color.
+func cssColor(c gc.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
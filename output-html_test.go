@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMarkedToHTML checks that nested colorizing turns into nested spans and
+// that everything else is escaped.
+func TestMarkedToHTML(t *testing.T) {
+	ch := &ColorHTML{}
+
+	marked := ch.C(ColorMessage, "a <b> & "+ch.C(ColorWorryError, "failed")+" again")
+
+	assert.Equal(t,
+		`<span class="c-message">a &lt;b&gt; &amp; <span class="c-worry-err">failed</span> again</span>`,
+		markedToHTML(marked),
+		"nested spans, escaped text",
+	)
+	assert.Equal(t, "c-date-time", htmlColorClass(ColorDateTime), "class names are CSS-safe")
+}
+
+// TestHTMLFormatter checks the page structure: the palette in the stylesheet,
+// per-line anchors, the level of each line, and collapsible extracted fields.
+func TestHTMLFormatter(t *testing.T) {
+	origWorry := highlightWorryWords
+	t.Cleanup(func() { highlightWorryWords = origWorry })
+	highlightWorryWords = true
+
+	out := &bytes.Buffer{}
+	hf := NewHTMLFormatter(out, DefaultPalette, true, "ts", `{{with index . "msg"}}{{.}}{{end}}`, []string{"level", "msg", "stacktrace", "error", "ts"}, &TimeDisplay{})
+
+	hf.FormatEntry(map[string]any{
+		"ts":    time.Date(2026, 8, 13, 14, 22, 10, 4_000_000, time.UTC),
+		"level": "error",
+		"msg":   "request failed",
+		"error": "dial tcp <10.0.0.7>: connection refused",
+	})
+	hf.FormatRaw("Starting up")
+	assert.NoError(t, hf.Close(), "close finishes the page")
+
+	page := out.String()
+
+	assert.True(t, strings.HasPrefix(page, "<!DOCTYPE html>"), "page has a head")
+	assert.True(t, strings.HasSuffix(page, "</html>\n"), "page is finished")
+	assert.Contains(t, page, ".c-level-error { color: #ffd700; }", "palette is in the stylesheet")
+	assert.Contains(t, page, `<div class="entry" id="L1" data-level="error"><a class="ln" href="#L1">1</a>`, "first line anchored with its level")
+	assert.Contains(t, page, `<span class="c-level-error">ERROR</span>`, "level colorized")
+	assert.Contains(t, page, `<span class="c-worry-err">failed</span>`, "worry words colorized")
+	assert.Contains(t, page, `<details><summary>error</summary><pre>`, "extracted fields collapsible")
+	assert.Contains(t, page, `dial tcp &lt;10.0.0.7&gt;: </span><span class="c-worry-err">connection refused</span>`, "extracted text escaped, with the worry phrase colorized")
+	assert.Contains(t, page, `<div class="entry" id="L2" data-level="raw">`, "raw line anchored")
+	assert.NotContains(t, page, string(htmlMarkOpen), "no marks left behind")
+}
+
+// TestHTMLFormatterColorOff checks that turning color off leaves plain text.
+func TestHTMLFormatterColorOff(t *testing.T) {
+	out := &bytes.Buffer{}
+	hf := NewHTMLFormatter(out, DefaultPalette, false, "ts", `{{with index . "msg"}}{{.}}{{end}}`, []string{"level", "msg", "ts"}, &TimeDisplay{})
+
+	hf.FormatEntry(map[string]any{"level": "info", "msg": "hello"})
+
+	assert.Contains(t, out.String(), `<span class="text">0000-00-00T00:00:00.000000-00:00 INFO   hello</span>`, "no color spans")
+}
+
+// TestHTMLFormatterEmpty checks that an empty log still makes a whole page.
+func TestHTMLFormatterEmpty(t *testing.T) {
+	out := &bytes.Buffer{}
+	hf := NewHTMLFormatter(out, DefaultPalette, true, "ts", `{{with index . "msg"}}{{.}}{{end}}`, nil, &TimeDisplay{})
+
+	assert.NoError(t, hf.Close(), "close finishes the page")
+	assert.True(t, strings.HasPrefix(out.String(), "<!DOCTYPE html>"), "page has a head")
+	assert.True(t, strings.HasSuffix(out.String(), "</html>\n"), "page is finished")
+}
+
+// TestHTMLFormatterLightTheme checks that a palette for a light background gets
+// a light page, where its dark text is readable.
+func TestHTMLFormatterLightTheme(t *testing.T) {
+	out := &bytes.Buffer{}
+	hf := NewHTMLFormatter(out, LightPalette, true, "ts", `{{with index . "msg"}}{{.}}{{end}}`, nil, &TimeDisplay{})
+	require.NoError(t, hf.Close())
+	assert.Contains(t, out.String(), "body { margin: 0; background: #fdfdfd; color: #303030;", "light page")
+
+	out.Reset()
+	hf = NewHTMLFormatter(out, DefaultPalette, true, "ts", `{{with index . "msg"}}{{.}}{{end}}`, nil, &TimeDisplay{})
+	require.NoError(t, hf.Close())
+	assert.Contains(t, out.String(), "body { margin: 0; background: #1e1e1e; color: #dddddd;", "dark page")
+}
+
+// TestCSSStyle checks the CSS written for each part of a style.
+func TestCSSStyle(t *testing.T) {
+	assert.Equal(t, "color: #ff0000;", cssStyle(Fg(0xff, 0x00, 0x00), "#dddddd", "#1e1e1e"), "color only")
+	assert.Equal(t, "color: #ffffff; background-color: #ff0000; font-weight: bold;",
+		cssStyle(Style{FG: RGB(0xff, 0xff, 0xff), BG: RGB(0xff, 0x00, 0x00), Bold: true}, "#dddddd", "#1e1e1e"), "bold on red")
+	assert.Equal(t, "opacity: 0.6; font-style: italic; text-decoration: underline;",
+		cssStyle(Style{Dim: true, Italic: true, Underline: true}, "#dddddd", "#1e1e1e"), "attributes only")
+	assert.Equal(t, "color: #1e1e1e; background-color: #ff0000;",
+		cssStyle(Style{FG: RGB(0xff, 0x00, 0x00), Reverse: true}, "#dddddd", "#1e1e1e"), "reversed onto the page color")
+}
+
+// TestHTMLFormatterMarksInInput checks that the private-use runes used as span
+// marks are replaced where the log itself contains them.
+func TestHTMLFormatterMarksInInput(t *testing.T) {
+	out := &bytes.Buffer{}
+	hf := NewHTMLFormatter(out, DefaultPalette, true, "ts", `{{with index . "msg"}}{{.}}{{end}}`, []string{"level", "msg", "ts"}, &TimeDisplay{})
+
+	hf.FormatEntry(map[string]any{
+		"level":     "info",
+		"msg":       "odd \uE000level\uE001 text\uE002 here",
+		"key\uE002": []any{"\uE000"},
+	})
+	hf.FormatRaw("raw \uE002\uE000 line")
+	assert.NoError(t, hf.Close(), "close finishes the page")
+
+	page := out.String()
+
+	assert.Contains(t, page, "odd \uFFFDlevel\uFFFD text\uFFFD here", "marks in the message replaced")
+	assert.Contains(t, page, "raw \uFFFD\uFFFD line", "marks in a raw line replaced")
+	for _, mark := range []rune{htmlMarkOpen, htmlMarkName, htmlMarkClose} {
+		assert.NotContains(t, page, string(mark), "no marks left behind")
+	}
+	assert.Equal(t, strings.Count(page, "<span"), strings.Count(page, "</span>"), "spans balanced")
+}
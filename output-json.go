@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// JSONFormatter writes each log entry as one JSON object per line, with the
+// timestamp, level, and message normalized under fixed keys, so logfmt can
+// normalize mixed logs for downstream ingestion. It never colorizes.
+type JSONFormatter struct {
+	out        io.Writer
+	tsField    string
+	msgFormat  string
+	dropFields []string
+}
+
+// NewJSONFormatter builds the JSON formatter. Trimmed fields are dropped the
+// same way the logfmt formatter drops them.
+func NewJSONFormatter(
+	out io.Writer,
+	tsField, msgFormat string,
+	trimFields []string,
+) *JSONFormatter {
+	return &JSONFormatter{
+		out:        out,
+		tsField:    tsField,
+		msgFormat:  msgFormat,
+		dropFields: dropFieldsOf(trimFields),
+	}
+}
+
+// FormatRaw outputs the line wrapped as {"msg":"...","raw":true}.
+func (jf *JSONFormatter) FormatRaw(line string) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	writeJSONPair(buf, "msg", line)
+	writeJSONPair(buf, "raw", true)
+	buf.WriteString("}\n")
+	_, _ = jf.out.Write(buf.Bytes())
+}
+
+// FormatEntry outputs the entry with the timestamp in UTC RFC 3339 under "ts",
+// the canonical lowercase level under "level", and the message under "msg",
+// followed by the remaining fields sorted by name. A missing timestamp or level
+// is left out. See trailingFields for fields named like the fixed keys.
+func (jf *JSONFormatter) FormatEntry(lineData map[string]any) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+
+	if ts, err := getTime(lineData, jf.tsField); err == nil && !ts.IsZero() {
+		writeJSONPair(buf, "ts", ts.UTC().Format(time.RFC3339Nano))
+	} else if text, err := getString(lineData, jf.tsField); err == nil {
+		writeJSONPair(buf, "ts", text)
+	}
+
+	if level := getLevel(lineData); level != "" {
+		writeJSONPair(buf, "level", canonicalLevel(level))
+	}
+	if levelInferred(lineData) {
+		writeJSONPair(buf, levelInferredKey, true)
+	}
+
+	writeJSONPair(buf, "msg", formatMessage(lineData, jf.msgFormat))
+
+	for _, f := range trailingFields(lineData, jf.tsField, jf.dropFields) {
+		writeJSONPair(buf, f.key, lineData[f.name])
+	}
+
+	buf.WriteString("}\n")
+	_, _ = jf.out.Write(buf.Bytes())
+}
+
+func (jf *JSONFormatter) Close() error {
+	return nil
+}
+
+// writeJSONPair appends "key":value to the JSON object being written to buf,
+// preceded by a comma unless it is the first member.
+func writeJSONPair(buf *bytes.Buffer, key string, value any) {
+	if buf.Len() > 1 {
+		buf.WriteByte(',')
+	}
+
+	keyBytes, _ := marshalJSON(key)
+	buf.Write(keyBytes)
+	buf.WriteByte(':')
+
+	valueBytes, err := marshalJSON(value)
+	if err != nil {
+		valueBytes, _ = marshalJSON(fmt.Sprint(value))
+	}
+	buf.Write(valueBytes)
+}
+
+// marshalJSON works like json.Marshal, but leaves <, >, and & alone, since the
+// output is not headed for HTML.
+func marshalJSON(v any) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestJSONFormatterEntry checks the normalized keys, their order, and that the
+// other fields are preserved.
+func TestJSONFormatterEntry(t *testing.T) {
+	out := &bytes.Buffer{}
+	jf := NewJSONFormatter(out, "timestamp", `{{index . "message"}}`, []string{"level", "message", "stacktrace", "noisy", "timestamp"})
+
+	origLvl, origMsg := lvlField, msgField
+	t.Cleanup(func() { lvlField, msgField = origLvl, origMsg })
+	lvlField, msgField = "severity", "message"
+
+	jf.FormatEntry(map[string]any{
+		"timestamp":  time.Date(2026, 8, 13, 9, 22, 9, 482_000_000, time.FixedZone("CDT", -5*60*60)),
+		"severity":   "WARNING",
+		"message":    "a < b & c",
+		"attempt":    2.0,
+		"gone":       nil,
+		"noisy":      "dropped",
+		"stacktrace": "main.main\n\tmain.go:1",
+		"nested":     map[string]any{"a": []any{1.0, "x"}},
+	})
+
+	assert.Equal(t,
+		`{"ts":"2026-08-13T14:22:09.482Z","level":"warn","msg":"a < b & c","attempt":2,"nested":{"a":[1,"x"]},"stacktrace":"main.main\n\tmain.go:1"}`+"\n",
+		out.String(),
+		"normalized JSON line",
+	)
+}
+
+// TestJSONFormatterSparseEntry checks that a missing timestamp and level are
+// left out rather than written empty.
+func TestJSONFormatterSparseEntry(t *testing.T) {
+	out := &bytes.Buffer{}
+	jf := NewJSONFormatter(out, "ts", `{{with index . "msg"}}{{.}}{{end}}`, []string{"msg", "ts"})
+
+	jf.FormatEntry(map[string]any{"ts": time.Time{}})
+
+	assert.Equal(t, `{"msg":""}`+"\n", out.String(), "only the message is written")
+}
+
+// TestJSONFormatterNoMessage checks that, with the message format
+// setupFormatter builds, an entry without a message gets an empty one.
+func TestJSONFormatterNoMessage(t *testing.T) {
+	origFormat, origTrim := outputFormat, trimFields
+	t.Cleanup(func() { outputFormat, trimFields = origFormat, origTrim })
+	outputFormat, trimFields = "json", []string{"level", "msg"}
+
+	out := &bytes.Buffer{}
+	setupFormatter(out, &TimeDisplay{}).FormatEntry(map[string]any{"level": "info", "foo": 1.0})
+
+	assert.Equal(t, `{"level":"info","msg":"","foo":1}`+"\n", out.String(), "empty message, not <no value>")
+}
+
+// TestJSONFormatterRenamedFields checks that, with the timestamp, level, and
+// message in other fields, fields named ts, level, and msg do not repeat the
+// fixed keys.
+func TestJSONFormatterRenamedFields(t *testing.T) {
+	out := &bytes.Buffer{}
+	jf := NewJSONFormatter(out, "time", `{{index . "message"}}`, []string{"time", "message"})
+
+	origLvl, origMsg := lvlField, msgField
+	t.Cleanup(func() { lvlField, msgField = origLvl, origMsg })
+	lvlField, msgField = "severity", "message"
+
+	jf.FormatEntry(map[string]any{
+		"time":      time.Date(2026, 8, 13, 14, 22, 3, 0, time.UTC),
+		"severity":  "info",
+		"message":   "yo",
+		"ts":        "x",
+		"level":     "not the level",
+		"msg":       "hi",
+		"fields.ts": "taken",
+	})
+
+	assert.Equal(t,
+		`{"ts":"2026-08-13T14:22:03Z","level":"info","msg":"yo","fields.ts":"taken","fields.level":"not the level","fields.msg":"hi","fields.fields.ts":"x"}`+"\n",
+		out.String(),
+		"colliding fields renamed",
+	)
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &got), "output is valid JSON")
+	assert.Len(t, got, 7, "no key written twice")
+}
+
+func TestJSONFormatterRaw(t *testing.T) {
+	out := &bytes.Buffer{}
+	jf := NewJSONFormatter(out, "ts", `{{index . "msg"}}`, nil)
+
+	jf.FormatRaw(`Starting up: "quoted" \ text`)
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &got), "raw output is valid JSON")
+	assert.Equal(t, map[string]any{"msg": `Starting up: "quoted" \ text`, "raw": true}, got, "raw line wrapped")
+}
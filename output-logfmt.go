@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// LogfmtFormatter re-emits each log entry as a canonical logfmt line of the
+// form `ts=... level=... msg="..." k=v`, so logfmt can normalize mixed logs for
+// tools that consume logfmt. It never colorizes.
+type LogfmtFormatter struct {
+	out        io.Writer
+	tsField    string
+	msgFormat  string
+	dropFields []string
+}
+
+// NewLogfmtFormatter builds the logfmt formatter. Trimmed fields are dropped,
+// except for those also being extracted, which the console format displays
+// below the entry rather than throwing away.
+func NewLogfmtFormatter(
+	out io.Writer,
+	tsField, msgFormat string,
+	trimFields []string,
+) *LogfmtFormatter {
+	return &LogfmtFormatter{
+		out:        out,
+		tsField:    tsField,
+		msgFormat:  msgFormat,
+		dropFields: dropFieldsOf(trimFields),
+	}
+}
+
+// dropFieldsOf returns the trimmed fields that are not also extracted fields.
+func dropFieldsOf(trimFields []string) []string {
+	dropFields := make([]string, 0, len(trimFields))
+	for _, field := range trimFields {
+		if !slices.Contains(extractFields, field) {
+			dropFields = append(dropFields, field)
+		}
+	}
+	return dropFields
+}
+
+// fixedKeys are the keys the logfmt and JSON formats write the timestamp,
+// level, escalation, and message under.
+var fixedKeys = []string{"ts", "level", levelInferredKey, "msg"}
+
+// trailingField is a field written after the fixed keys, by its name in the
+// entry and the key it is written under.
+type trailingField struct {
+	name, key string
+}
+
+// trailingFields returns the fields written after the fixed keys, sorted by
+// name. The timestamp, level, and message fields are left out, since they are
+// written under the fixed keys, as are dropped fields and, unless --show-null
+// is set, null ones. Any other field named like a fixed key, as "ts" is when
+// the timestamp is in "time", is written as "fields.ts", the way logrus keeps
+// such fields apart.
+func trailingFields(lineData map[string]any, tsField string, dropFields []string) []trailingField {
+	names := make([]string, 0, len(lineData))
+	for k, v := range lineData {
+		if k == tsField || k == lvlField || k == msgField || slices.Contains(dropFields, k) {
+			continue
+		}
+		if v == nil && !showNull {
+			continue
+		}
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	fields := make([]trailingField, len(names))
+	for i, name := range names {
+		key := name
+		for slices.Contains(fixedKeys, key) || (key != name && hasField(lineData, key)) {
+			key = "fields." + key
+		}
+		fields[i] = trailingField{name, key}
+	}
+	return fields
+}
+
+// hasField reports whether the entry has the field.
+func hasField(lineData map[string]any, k string) bool {
+	_, ok := lineData[k]
+	return ok
+}
+
+// FormatRaw outputs the line as the message, marked raw=true.
+func (lf *LogfmtFormatter) FormatRaw(line string) {
+	sb := &strings.Builder{}
+	writeLogfmtPair(sb, "msg", line)
+	writeLogfmtPair(sb, "raw", "true")
+	_, _ = fmt.Fprintln(lf.out, sb.String())
+}
+
+// FormatEntry outputs ts, level, and msg, followed by the remaining fields
+// sorted by name. Nested values are written as JSON. See trailingFields for
+// fields named like the fixed keys.
+func (lf *LogfmtFormatter) FormatEntry(lineData map[string]any) {
+	sb := &strings.Builder{}
+
+	if ts, err := getTime(lineData, lf.tsField); err == nil && !ts.IsZero() {
+		writeLogfmtPair(sb, "ts", ts.Format(time.RFC3339Nano))
+	} else if text, err := getString(lineData, lf.tsField); err == nil {
+		writeLogfmtPair(sb, "ts", text)
+	}
+
+	if level := getLevel(lineData); level != "" {
+		writeLogfmtPair(sb, "level", canonicalLevel(level))
+	}
+	if levelInferred(lineData) {
+		writeLogfmtPair(sb, levelInferredKey, "true")
+	}
+
+	writeLogfmtPair(sb, "msg", formatMessage(lineData, lf.msgFormat))
+
+	for _, f := range trailingFields(lineData, lf.tsField, lf.dropFields) {
+		writeLogfmtPair(sb, f.key, fieldString(lineData[f.name]))
+	}
+
+	_, _ = fmt.Fprintln(lf.out, sb.String())
+}
+
+func (lf *LogfmtFormatter) Close() error {
+	return nil
+}
+
+// writeLogfmtPair appends key=value to sb, separated from whatever came before
+// by a space. The value is quoted when needed and the key has any characters
+// that logfmt does not allow in a key replaced.
+func writeLogfmtPair(sb *strings.Builder, key, value string) {
+	if sb.Len() > 0 {
+		sb.WriteByte(' ')
+	}
+	sb.WriteString(logfmtKey(key))
+	sb.WriteByte('=')
+	sb.WriteString(logfmtValue(value))
+}
+
+// logfmtNeedsQuote reports whether r cannot appear in a bare logfmt key or
+// value.
+func logfmtNeedsQuote(r rune) bool {
+	return r <= ' ' || r == '=' || r == '"' || r == unicode.ReplacementChar || !unicode.IsPrint(r)
+}
+
+// logfmtKey replaces the characters logfmt does not allow in a key with
+// underscores.
+func logfmtKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if logfmtNeedsQuote(r) {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+// logfmtValue quotes the value if it is empty or contains characters that are
+// not allowed in a bare value.
+func logfmtValue(value string) string {
+	if value == "" || strings.IndexFunc(value, logfmtNeedsQuote) >= 0 {
+		return strconv.Quote(value)
+	}
+	return value
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLogfmtFormatterEntry checks the canonical field order, the quoting, and
+// which fields are kept.
+func TestLogfmtFormatterEntry(t *testing.T) {
+	out := &bytes.Buffer{}
+	lf := NewLogfmtFormatter(out, "ts", `{{index . "msg"}}`, []string{"level", "msg", "stacktrace", "error", "noisy", "ts"})
+
+	lf.FormatEntry(map[string]any{
+		"ts":         time.Date(2026, 8, 13, 14, 22, 9, 482_000_000, time.UTC),
+		"level":      "WARN",
+		"msg":        "upstream returned 503, retrying",
+		"upstream":   "cart-svc",
+		"attempt":    2.0,
+		"tls":        true,
+		"empty":      "",
+		"gone":       nil,
+		"noisy":      "dropped",
+		"error":      "dial tcp: connection refused",
+		"nested":     map[string]any{"a": 1.0},
+		"weird key=": `say "hi"`,
+	})
+
+	assert.Equal(t,
+		`ts=2026-08-13T14:22:09.482Z level=warn msg="upstream returned 503, retrying" attempt=2 empty="" error="dial tcp: connection refused" nested="{\"a\":1}" tls=true upstream=cart-svc weird_key_="say \"hi\""`+"\n",
+		out.String(),
+		"canonical logfmt line",
+	)
+}
+
+// TestLogfmtFormatterSparseEntry checks that a missing timestamp and level are
+// left out rather than written empty.
+func TestLogfmtFormatterSparseEntry(t *testing.T) {
+	out := &bytes.Buffer{}
+	lf := NewLogfmtFormatter(out, "ts", `{{index . "msg"}}`, []string{"msg", "ts"})
+
+	lf.FormatEntry(map[string]any{"ts": time.Time{}, "msg": "multi\nline\ttext"})
+
+	assert.Equal(t, `msg="multi\nline\ttext"`+"\n", out.String(), "only the message is written")
+}
+
+// TestLogfmtFormatterNoMessage checks that, with the message format
+// setupFormatter builds, an entry without a message gets an empty one.
+func TestLogfmtFormatterNoMessage(t *testing.T) {
+	origFormat, origTrim := outputFormat, trimFields
+	t.Cleanup(func() { outputFormat, trimFields = origFormat, origTrim })
+	outputFormat, trimFields = "logfmt", []string{"level", "msg"}
+
+	out := &bytes.Buffer{}
+	setupFormatter(out, &TimeDisplay{}).FormatEntry(map[string]any{"level": "info", "foo": 1.0})
+
+	assert.Equal(t, "level=info msg=\"\" foo=1\n", out.String(), "empty message, not <no value>")
+}
+
+// TestLogfmtFormatterRenamedFields checks that, with the timestamp, level, and
+// message in other fields, fields named ts, level, and msg do not repeat the
+// fixed keys.
+func TestLogfmtFormatterRenamedFields(t *testing.T) {
+	out := &bytes.Buffer{}
+	lf := NewLogfmtFormatter(out, "time", `{{index . "message"}}`, []string{"time", "message"})
+
+	origLvl, origMsg := lvlField, msgField
+	t.Cleanup(func() { lvlField, msgField = origLvl, origMsg })
+	lvlField, msgField = "severity", "message"
+
+	lf.FormatEntry(map[string]any{
+		"time":     time.Date(2026, 8, 13, 14, 22, 3, 0, time.UTC),
+		"severity": "info",
+		"message":  "yo",
+		"ts":       "x",
+		"msg":      "hi",
+	})
+
+	assert.Equal(t, "ts=2026-08-13T14:22:03Z level=info msg=yo fields.msg=hi fields.ts=x\n", out.String(), "colliding fields renamed")
+}
+
+func TestLogfmtFormatterRaw(t *testing.T) {
+	out := &bytes.Buffer{}
+	lf := NewLogfmtFormatter(out, "ts", `{{index . "msg"}}`, nil)
+
+	lf.FormatRaw("Starting up: no JSON here")
+	lf.FormatRaw("bare")
+
+	assert.Equal(t, "msg=\"Starting up: no JSON here\" raw=true\nmsg=bare raw=true\n", out.String(), "raw lines become the message")
+}
@@ -4,25 +4,103 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"regexp"
 	"strings"
 	"text/template"
 	"time"
 )
 
+// LineFormatter renders log lines in one of the --output-format formats.
+type LineFormatter interface {
+	// FormatRaw outputs a line that failed to be parsed.
+	FormatRaw(line string)
+
+	// FormatEntry outputs a parsed log entry.
+	FormatEntry(lineData map[string]any)
+
+	// Close finishes the output after the last line.
+	Close() error
+}
+
+// ConsoleFormatter is the default output format, the colorized layout described
+// for outputFormattedLogLine.
+type ConsoleFormatter struct {
+	out        io.Writer
+	c          *SugaredColorizer
+	tsField    string
+	msgFormat  string
+	trimFields []string
+	times      *TimeDisplay
+}
+
+// NewConsoleFormatter builds the console formatter.
+func NewConsoleFormatter(
+	out io.Writer,
+	c *SugaredColorizer,
+	tsField, msgFormat string,
+	trimFields []string,
+	times *TimeDisplay,
+) *ConsoleFormatter {
+	return &ConsoleFormatter{
+		out:        out,
+		c:          c,
+		tsField:    tsField,
+		msgFormat:  msgFormat,
+		trimFields: trimFields,
+		times:      times,
+	}
+}
+
+func (cf *ConsoleFormatter) FormatRaw(line string) {
+	outputRawLogLine(cf.out, cf.c, line)
+}
+
+func (cf *ConsoleFormatter) FormatEntry(lineData map[string]any) {
+	outputFormattedLogLine(cf.out, cf.c, lineData, cf.tsField, cf.msgFormat, cf.trimFields, cf.times)
+}
+
+func (cf *ConsoleFormatter) Close() error {
+	return nil
+}
+
+// formatMessage renders the message of the log entry using msgFormat.
+func formatMessage(lineData map[string]any, msgFormat string) string {
+	sw := &strings.Builder{}
+	msgT := template.Must(template.New(msgField).Parse(msgFormat))
+	_ = msgT.Execute(sw, lineData)
+	return sw.String()
+}
+
+// fieldString converts a field value to text for the output formats that
+// write values as plain text. Nested values are written as JSON.
+func fieldString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(bs)
+}
+
 // outputRawLogLine outputs a line that failed to be parsed.
 func outputRawLogLine(out io.Writer, c *SugaredColorizer, line string) {
-	if highlightWorryWords {
+	if highlightWorriesIn("raw") {
 		line = HighlightWorries(c, line)
 	}
 	_, _ = fmt.Fprintln(out, c.C(ColorNormal, line))
 }
 
 // outputFormattedLogLine will take a parsed log line and pretty print it. The
-// format is "TS LEVEL MSG {EXTRA}". The TS is an RFC3339Nano formatted time
-// stamp. The LEVEL is the log level. The MSG is the text of the message. The
+// format is "TS LEVEL MSG {EXTRA}". The TS is the time stamp, rendered by
+// times. The LEVEL is the log level. The MSG is the text of the message. The
 // EXTRA is omitted if no additional fields are present. If additional fields
-// are present, those are converted back to JSON and rendered. If a stacktrace
+// are present, those are converted back to JSON and rendered, or expanded into
+// a tree below the line, depending on --expand. If a stacktrace
 // is present, it will be output indented below the log line.
 func outputFormattedLogLine(
 	out io.Writer,
@@ -30,60 +108,41 @@ func outputFormattedLogLine(
 	lineData map[string]any,
 	tsField, msgFormat string,
 	trimFields []string,
+	times *TimeDisplay,
 ) {
-	tsTimeStr := "0000-00-00T00:00:00.000000-00:00"
-	if tsTime, err := getTime(lineData, tsField); err == nil {
-		tsTimeStr = tsTime.Format(time.RFC3339Nano)
-	}
+	tsTimeStr := times.Format(lineData, tsField)
 
-	level, _ := getString(lineData, lvlField)
-	level = strings.ToUpper(level)
+	level := getLevel(lineData)
+	level = displayLevel(level)
+	levelColorName := LevelToColorName(level)
+	level = markEscalated(lineData, level)
 
-	sw := &strings.Builder{}
-	msgT := template.Must(template.New(msgField).Parse(msgFormat))
-	_ = msgT.Execute(sw, lineData)
-	msg := sw.String()
-	// msg, _ := getString(lineData, "msg")
-	// if err == nil {
-	// 	delete(lineData, "msg")
-	// }
+	msg := formatMessage(lineData, msgFormat)
 
-	extracts := map[string]string{}
-	for _, extractField := range extractFields {
-		extracts[extractField], _ = getString(lineData, extractField)
-	}
+	extracts := getExtracts(lineData)
 
 	for _, field := range trimFields {
 		delete(lineData, field)
 	}
 
-	if highlightWorryWords {
+	valueColors := identityColors(lineData)
+	applyRenderRules(lineData, valueColors)
+
+	if highlightWorriesIn("message") {
 		msg = HighlightWorries(c, msg)
 	}
 
 	f := "%s %-6s %s"
-	levelColorName := LevelToColorName(level)
 	args := []any{
 		c.C(ColorDateTime, tsTimeStr),
 		c.C(levelColorName, level),
 		c.C(ColorMessage, msg),
 	}
 
-	if len(lineData) > 0 {
-		if !showNull {
-			keepData := make(map[string]any, len(lineData))
-			for k, v := range lineData {
-				if v != nil {
-					keepData[k] = v
-				}
-			}
-			lineData = keepData
-		}
-
-		// If this has an error, we have something in the logs that can be
-		// parsed from JSON but not put back into JSON? Seems unlikely.
-		lineDataBytes, _ := json.Marshal(lineData)
-		coloredDataBytes := colorizeDataBytes(c, lineDataBytes)
+	lineDataBytes := marshalTrailingData(lineData)
+	expanded := lineDataBytes != nil && expandData(lineDataBytes)
+	if lineDataBytes != nil && !expanded {
+		coloredDataBytes := colorizeDataBytes(c, lineDataBytes, valueColors)
 
 		f += " %s"
 		args = append(args, c.C(ColorData, string(coloredDataBytes)))
@@ -92,6 +151,50 @@ func outputFormattedLogLine(
 	f += "\n"
 	_, _ = fmt.Fprintf(out, f, args...)
 
+	if expanded {
+		outputDataTree(out, c, lineDataBytes, valueColors)
+	}
+
+	outputExtracts(out, c, extracts)
+}
+
+// getExtracts collects the values of the extracted fields, which must be done
+// before they are trimmed.
+func getExtracts(lineData map[string]any) map[string]string {
+	extracts := map[string]string{}
+	for _, extractField := range extractFields {
+		extracts[extractField], _ = getString(lineData, extractField)
+	}
+	return extracts
+}
+
+// marshalTrailingData converts the fields remaining after trimming back to
+// JSON, leaving out null values unless --show-null is set. It returns nil if no
+// fields remain.
+func marshalTrailingData(lineData map[string]any) []byte {
+	if len(lineData) == 0 {
+		return nil
+	}
+
+	if !showNull {
+		keepData := make(map[string]any, len(lineData))
+		for k, v := range lineData {
+			if v != nil {
+				keepData[k] = v
+			}
+		}
+		lineData = keepData
+	}
+
+	// If this has an error, we have something in the logs that can be
+	// parsed from JSON but not put back into JSON? Seems unlikely.
+	lineDataBytes, _ := json.Marshal(lineData)
+	return lineDataBytes
+}
+
+// outputExtracts outputs the extracted fields, each indented on its own lines
+// below the log entry.
+func outputExtracts(out io.Writer, c *SugaredColorizer, extracts map[string]string) {
 	for _, extractField := range extractFields {
 		color := ColorExtracted
 		switch extractField {
@@ -103,21 +206,15 @@ func outputFormattedLogLine(
 
 		if ex, hasEx := extracts[extractField]; hasEx && ex != "" {
 			ex = insertIndent(strings.TrimSpace(ex), 4)
+			if highlightWorriesIn("extracted") {
+				_, _ = fmt.Fprintln(out, colorWorries(c, ex, color))
+				continue
+			}
 			_, _ = fmt.Fprintln(out, c.C(color, ex))
 		}
 	}
 }
 
-var dataLiteral = regexp.MustCompile(`"(?:[^\\"]+|\\.)*"|'(?:[^\\']+|\\.)*'|\d+`)
-
-// colorizeDataBytes finds strings and numbers and colorizes them using
-// "data-literal."
-func colorizeDataBytes(c *SugaredColorizer, bs []byte) []byte {
-	return dataLiteral.ReplaceAllFunc(bs, func(mbs []byte) []byte {
-		return []byte(c.C(ColorDataLiteral, string(mbs)))
-	})
-}
-
 // insertIndent is a function that will insert i spaces before each start of
 // line in the given string.
 func insertIndent(st string, i int) string {
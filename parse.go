@@ -18,13 +18,31 @@ var (
 type LineParser func([]byte, string) (map[string]any, error)
 
 var lineParsers = []LineParser{
+	parseClefLogLine,
+	parseK8sAuditLogLine,
 	parseJsonLogLine,
+	parsePostgresLogLine,
+	parseMySQLLogLine,
+	parseRedisLogLine,
 	parseZapConsoleLikeLogLine,
 }
 
+// LineExpander parses a line that carries a batch of log entries, such as an
+// OTLP export, and returns every entry found.
+type LineExpander func([]byte, string) ([]map[string]any, error)
+
+var lineExpanders = []LineExpander{
+	parseOtlpLogLine,
+}
+
 var lineParsersWithAccessLogs = []LineParser{
+	parseClefLogLine,
+	parseK8sAuditLogLine,
 	parseJsonLogLine,
 	parseAccessLogLine,
+	parsePostgresLogLine,
+	parseMySQLLogLine,
+	parseRedisLogLine,
 	parseZapConsoleLikeLogLine,
 }
 
@@ -159,7 +177,7 @@ func parseWord(line []byte) (string, []byte, error) {
 // all succeeds, we return the structured data parsed out.
 func parseStructure(line []byte) (map[string]any, []byte, error) {
 	line = bytes.TrimRight(line, WS)
-	if line[len(line)-1] != '}' {
+	if len(line) == 0 || line[len(line)-1] != '}' {
 		return nil, nil, ErrUnparseable
 	}
 
@@ -257,3 +275,21 @@ func parseLogLine(line []byte, tsField string) (map[string]any, error) {
 
 	return nil, ErrUnparseable
 }
+
+// parseLogEntries parses a line into the log entries it holds. Batch formats
+// are tried first, since they would otherwise be taken for a single JSON entry.
+// Anything else is handed to parseLogLine and yields exactly one entry.
+func parseLogEntries(line []byte, tsField string) ([]map[string]any, error) {
+	for _, lineExpander := range lineExpanders {
+		if entries, err := lineExpander(line, tsField); err == nil {
+			return entries, nil
+		}
+	}
+
+	lineData, err := parseLogLine(line, tsField)
+	if err != nil {
+		return nil, err
+	}
+
+	return []map[string]any{lineData}, nil
+}
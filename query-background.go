@@ -0,0 +1,64 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	gc "image/color"
+	"io"
+	"os"
+	"regexp"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// queryBackground asks the controlling terminal for its background color with
+// an OSC 11 query. The query is followed by a primary device attributes query,
+// which every terminal answers, so a terminal that ignores OSC 11 is noticed
+// without waiting for the whole timeout.
+func queryBackground(timeout time.Duration) (gc.Color, bool) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, false
+	}
+	defer func() { _ = tty.Close() }()
+
+	fd := int(tty.Fd())
+	orig, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return nil, false
+	}
+
+	// Turn off echo and line buffering so the answer is neither shown nor held
+	// until a newline, and make reads give up after a tenth of a second.
+	raw := *orig
+	raw.Lflag &^= unix.ECHO | unix.ICANON
+	raw.Cc[unix.VMIN] = 0
+	raw.Cc[unix.VTIME] = 1
+	if err := unix.IoctlSetTermios(fd, ioctlSetTermios, &raw); err != nil {
+		return nil, false
+	}
+	defer func() { _ = unix.IoctlSetTermios(fd, ioctlSetTermios, orig) }()
+
+	if _, err := io.WriteString(tty, "\x1b]11;?\x1b\\\x1b[c"); err != nil {
+		return nil, false
+	}
+
+	var resp []byte
+	buf := make([]byte, 256)
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) && !deviceAttributes.Match(resp) {
+		// Read the descriptor directly, so the read times out as set above
+		// instead of waiting in the runtime poller.
+		n, err := unix.Read(fd, buf)
+		if err != nil {
+			break
+		}
+		resp = append(resp, buf[:n]...)
+	}
+
+	return parseOSC11(resp)
+}
+
+// deviceAttributes matches the answer to a primary device attributes query.
+var deviceAttributes = regexp.MustCompile(`\x1b\[\?[0-9;]*c`)
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package main
+
+import (
+	gc "image/color"
+	"time"
+)
+
+// queryBackground cannot query the terminal here, where the ioctls that put it
+// in raw mode are not known, so the background is left to COLORFGBG.
+func queryBackground(timeout time.Duration) (gc.Color, bool) {
+	return nil, false
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RenderRuleConfig is a render rule as written in the configuration.
+type RenderRuleConfig struct {
+	Field      string            `yaml:"field" mapstructure:"field"`
+	As         string            `yaml:"as" mapstructure:"as"`
+	Unit       string            `yaml:"unit,omitempty" mapstructure:"unit"`
+	Length     int               `yaml:"length,omitempty" mapstructure:"length"`
+	Thresholds []ThresholdConfig `yaml:"thresholds,omitempty" mapstructure:"thresholds"`
+}
+
+// ThresholdConfig colors a rendered value when the value is above the limit.
+type ThresholdConfig struct {
+	Above string `yaml:"above" mapstructure:"above"`
+	Color string `yaml:"color" mapstructure:"color"`
+}
+
+// RenderRule formats the value of the fields it matches to make it easier to
+// read, such as a number of milliseconds as a duration, and colors it when it
+// passes a threshold.
+type RenderRule struct {
+	pattern    string
+	as         string
+	scale      float64
+	length     int
+	thresholds []renderThreshold
+}
+
+// renderThreshold is a threshold converted to the unit of the field's values.
+type renderThreshold struct {
+	above float64
+	color ColorName
+}
+
+// RenderRules are the rules applied to fields of the human-readable formats.
+var RenderRules []*RenderRule
+
+// renderAs are the accepted render rule kinds.
+var renderAs = []string{"duration", "bytes", "percent", "hash"}
+
+// durationUnits are the units a duration field may be counted in.
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// byteUnits are the units a byte size may be given in.
+var byteUnits = map[string]float64{
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// setupRenderRules compiles the render rules in the configuration.
+func setupRenderRules(c *Config) error {
+	rules := make([]*RenderRule, 0, len(c.Render))
+	for _, rc := range c.Render {
+		rule, err := NewRenderRule(rc, c.Colors)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, rule)
+	}
+
+	RenderRules = rules
+	return nil
+}
+
+// NewRenderRule compiles a render rule from its configuration. The colors of
+// its thresholds must be in the palette, built in or among the custom colors.
+func NewRenderRule(rc RenderRuleConfig, colors map[string]string) (*RenderRule, error) {
+	if _, err := path.Match(rc.Field, ""); err != nil || rc.Field == "" {
+		return nil, fmt.Errorf("invalid render rule field pattern %q", rc.Field)
+	}
+
+	rule := &RenderRule{
+		pattern: rc.Field,
+		as:      rc.As,
+		scale:   1,
+		length:  rc.Length,
+	}
+
+	switch rc.As {
+	case "duration":
+		unit, ok := durationUnits[strings.ToLower(cmp.Or(rc.Unit, "s"))]
+		if !ok {
+			return nil, fmt.Errorf("invalid unit %q for render rule on %q: expected one of ns, us, ms, s, m, h", rc.Unit, rc.Field)
+		}
+		rule.scale = float64(unit)
+
+	case "bytes":
+		unit, ok := byteUnits[strings.ToLower(cmp.Or(rc.Unit, "b"))]
+		if !ok {
+			return nil, fmt.Errorf("invalid unit %q for render rule on %q: expected one of B, KB, MB, GB, TB, KiB, MiB, GiB, TiB", rc.Unit, rc.Field)
+		}
+		rule.scale = unit
+
+	case "percent":
+		switch rc.Unit {
+		case "", "ratio":
+			rule.scale = 100
+		case "percent":
+		default:
+			return nil, fmt.Errorf("invalid unit %q for render rule on %q: expected ratio or percent", rc.Unit, rc.Field)
+		}
+
+	case "hash":
+		if rule.length <= 0 {
+			rule.length = 8
+		}
+		if len(rc.Thresholds) > 0 {
+			return nil, fmt.Errorf("render rule on %q: hash values have no thresholds", rc.Field)
+		}
+
+	default:
+		return nil, fmt.Errorf("invalid render rule %q on %q: expected one of %s", rc.As, rc.Field, strings.Join(renderAs, ", "))
+	}
+
+	for _, tc := range rc.Thresholds {
+		above, err := rule.parseThreshold(tc.Above)
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold %q for render rule on %q: %w", tc.Above, rc.Field, err)
+		}
+		_, builtin := DefaultPalette[ColorName(tc.Color)]
+		if _, custom := colors[tc.Color]; !builtin && !custom {
+			names := slices.Collect(maps.Keys(colors))
+			for name := range DefaultPalette {
+				names = append(names, string(name))
+			}
+			slices.Sort(names)
+			names = slices.Compact(names)
+			return nil, fmt.Errorf("invalid threshold color %q for render rule on %q: expected one of %s", tc.Color, rc.Field, strings.Join(names, ", "))
+		}
+		rule.thresholds = append(rule.thresholds, renderThreshold{above, ColorName(tc.Color)})
+	}
+
+	sort.Slice(rule.thresholds, func(i, j int) bool {
+		return rule.thresholds[i].above > rule.thresholds[j].above
+	})
+
+	return rule, nil
+}
+
+// parseThreshold converts a threshold to the unit of the field's values. A
+// plain number is already in that unit. Otherwise, a duration is given as a Go
+// duration, like 1.5s, a byte size with a unit, like 10MiB, and a percent with
+// a percent sign, like 90%.
+func (r *RenderRule) parseThreshold(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return n, nil
+	}
+
+	switch r.as {
+	case "duration":
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, err
+		}
+		return float64(d) / r.scale, nil
+
+	case "bytes":
+		i := strings.IndexFunc(s, func(c rune) bool {
+			return (c < '0' || c > '9') && c != '.'
+		})
+		if i > 0 {
+			unit, ok := byteUnits[strings.ToLower(strings.TrimSpace(s[i:]))]
+			n, err := strconv.ParseFloat(s[:i], 64)
+			if ok && err == nil {
+				return n * unit / r.scale, nil
+			}
+		}
+
+	case "percent":
+		if n, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64); err == nil && strings.HasSuffix(s, "%") {
+			return n / r.scale, nil
+		}
+	}
+
+	return 0, fmt.Errorf("not a %s", r.as)
+}
+
+// Matches reports whether the rule applies to the field.
+func (r *RenderRule) Matches(field string) bool {
+	ok, _ := path.Match(r.pattern, field)
+	return ok
+}
+
+// Render formats the value, and picks the color of the highest threshold it is
+// above, if any. Values the rule cannot make sense of are returned unchanged.
+func (r *RenderRule) Render(v any) (any, ColorName) {
+	if r.as == "hash" {
+		s, ok := v.(string)
+		if !ok || len([]rune(s)) <= r.length {
+			return v, ""
+		}
+		return string([]rune(s)[:r.length]) + "…", ""
+	}
+
+	n, ok := renderNumber(v)
+	if !ok {
+		return v, ""
+	}
+
+	var color ColorName
+	for _, t := range r.thresholds {
+		if n > t.above {
+			color = t.color
+			break
+		}
+	}
+
+	switch r.as {
+	case "duration":
+		return humanDuration(time.Duration(n * r.scale)), color
+	case "bytes":
+		return humanBytes(n * r.scale), color
+	default:
+		return trimFloat(n*r.scale, 2) + "%", color
+	}
+}
+
+// applyRenderRules renders the values of the fields matched by a rule, in
+// place, and sets the colors picked by thresholds in valueColors by field name.
+// The first rule to match a field wins.
+func applyRenderRules(lineData map[string]any, valueColors map[string]ColorName) {
+	for k, v := range lineData {
+		for _, rule := range RenderRules {
+			if !rule.Matches(k) {
+				continue
+			}
+
+			var color ColorName
+			lineData[k], color = rule.Render(v)
+			if color != "" {
+				valueColors[k] = color
+			}
+			break
+		}
+	}
+}
+
+// renderNumber converts a field value to a number, including numbers logged as
+// strings.
+func renderNumber(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// humanDuration rounds a duration to a readable precision.
+func humanDuration(d time.Duration) string {
+	switch abs := max(d, -d); {
+	case abs >= time.Second:
+		d = d.Round(time.Millisecond)
+	case abs >= time.Millisecond:
+		d = d.Round(time.Microsecond)
+	}
+	return d.String()
+}
+
+// humanBytes formats a byte size with binary units.
+func humanBytes(n float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	i := 0
+	for math.Abs(n) >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	return trimFloat(n, 1) + " " + units[i]
+}
+
+// trimFloat formats n with up to prec decimals, dropping trailing zeros.
+func trimFloat(n float64, prec int) string {
+	s := strconv.FormatFloat(n, 'f', prec, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRenderRule checks how each kind of rule formats values, and that values
+// it cannot make sense of are left alone.
+func TestRenderRule(t *testing.T) {
+	tests := []struct {
+		rule RenderRuleConfig
+		in   any
+		want any
+	}{
+		{RenderRuleConfig{Field: "duration_ms", As: "duration", Unit: "ms"}, 123456.0, "2m3.456s"},
+		{RenderRuleConfig{Field: "latency", As: "duration"}, 0.00321, "3.21ms"},
+		{RenderRuleConfig{Field: "latency", As: "duration"}, "1.5", "1.5s"},
+		{RenderRuleConfig{Field: "bytes", As: "bytes"}, 10485760.0, "10 MiB"},
+		{RenderRuleConfig{Field: "bytes", As: "bytes"}, int64(512), "512 B"},
+		{RenderRuleConfig{Field: "size_kb", As: "bytes", Unit: "KiB"}, 1536.0, "1.5 MiB"},
+		{RenderRuleConfig{Field: "hit_ratio", As: "percent"}, 0.0321, "3.21%"},
+		{RenderRuleConfig{Field: "cpu", As: "percent", Unit: "percent"}, 87.5, "87.5%"},
+		{RenderRuleConfig{Field: "commit", As: "hash"}, "4bc27e8a9f0c1d2e", "4bc27e8a…"},
+		{RenderRuleConfig{Field: "commit", As: "hash", Length: 4}, "4bc27e8a", "4bc2…"},
+		{RenderRuleConfig{Field: "commit", As: "hash"}, "4bc27e8", "4bc27e8"},
+		{RenderRuleConfig{Field: "bytes", As: "bytes"}, "lots", "lots"},
+	}
+
+	for _, test := range tests {
+		rule, err := NewRenderRule(test.rule, nil)
+		require.NoError(t, err, "rule %+v", test.rule)
+
+		got, color := rule.Render(test.in)
+		assert.Equal(t, test.want, got, "render %v with %+v", test.in, test.rule)
+		assert.Empty(t, color, "no thresholds, no color")
+	}
+}
+
+// TestRenderRuleThresholds checks that the highest threshold passed picks the
+// color, whatever unit the threshold is written in.
+func TestRenderRuleThresholds(t *testing.T) {
+	rule, err := NewRenderRule(RenderRuleConfig{
+		Field: "duration_ms",
+		As:    "duration",
+		Unit:  "ms",
+		Thresholds: []ThresholdConfig{
+			{Above: "1s", Color: "worry-warn"},
+			{Above: "5000", Color: "worry-err"},
+		},
+	}, nil)
+	require.NoError(t, err, "rule compiles")
+
+	_, color := rule.Render(500.0)
+	assert.Empty(t, color, "below every threshold")
+
+	_, color = rule.Render(1500.0)
+	assert.Equal(t, ColorWorryWarn, color, "above 1s")
+
+	_, color = rule.Render(7000.0)
+	assert.Equal(t, ColorWorryError, color, "above 5000ms")
+
+	sizes, err := NewRenderRule(RenderRuleConfig{Field: "bytes", As: "bytes", Thresholds: []ThresholdConfig{{Above: "1MiB", Color: "worry-warn"}}}, nil)
+	require.NoError(t, err, "byte rule compiles")
+
+	_, color = sizes.Render(2.0 * 1024 * 1024)
+	assert.Equal(t, ColorWorryWarn, color, "above 1MiB")
+}
+
+// TestNewRenderRuleInvalid checks that mistakes in the configuration are
+// reported.
+func TestNewRenderRuleInvalid(t *testing.T) {
+	bad := []RenderRuleConfig{
+		{Field: "", As: "duration"},
+		{Field: "[", As: "duration"},
+		{Field: "x", As: "speed"},
+		{Field: "x", As: "duration", Unit: "fortnights"},
+		{Field: "x", As: "duration", Thresholds: []ThresholdConfig{{Above: "soon"}}},
+		{Field: "x", As: "hash", Thresholds: []ThresholdConfig{{Above: "1"}}},
+		{Field: "x", As: "duration", Thresholds: []ThresholdConfig{{Above: "1s", Color: "worry-warning"}}},
+		{Field: "x", As: "bytes", Thresholds: []ThresholdConfig{{Above: "1MiB", Color: "red"}}},
+	}
+
+	for _, rc := range bad {
+		_, err := NewRenderRule(rc, nil)
+		assert.Error(t, err, "rule %+v is rejected", rc)
+	}
+}
+
+// TestNewRenderRuleCustomColor checks that a threshold may use a color set
+// under the custom colors as well as a built-in one.
+func TestNewRenderRuleCustomColor(t *testing.T) {
+	rc := RenderRuleConfig{Field: "x", As: "duration", Thresholds: []ThresholdConfig{{Above: "1s", Color: "level-audit"}}}
+
+	_, err := NewRenderRule(rc, nil)
+	assert.ErrorContains(t, err, `invalid threshold color "level-audit"`, "unknown color rejected")
+
+	_, err = NewRenderRule(rc, map[string]string{"level-audit": "#af87ff"})
+	assert.NoError(t, err, "custom color accepted")
+}
+
+// TestApplyRenderRules checks that rules match by pattern and that threshold
+// colors reach the colorized trailing data.
+func TestApplyRenderRules(t *testing.T) {
+	orig := RenderRules
+	t.Cleanup(func() { RenderRules = orig })
+
+	require.NoError(t, setupRenderRules(&Config{Render: []RenderRuleConfig{
+		{Field: "*_ms", As: "duration", Unit: "ms", Thresholds: []ThresholdConfig{{Above: "1s", Color: "worry-warn"}}},
+	}}), "rules compile")
+
+	out := &bytes.Buffer{}
+	c := NewSugaredColorizer(&ColorHTML{})
+	outputFormattedLogLine(out, c, map[string]any{"msg": "done", "db_ms": 1500.0, "n": 2.0}, "ts", `{{with index . "msg"}}{{.}}{{end}}`, []string{"msg", "ts"}, &TimeDisplay{})
+
+	page := markedToHTML(out.String())
+	assert.Contains(t, page, `<span class="c-worry-warn">&#34;1.5s&#34;</span>`, "rendered and colored")
+	assert.Contains(t, page, `<span class="c-data-number">2</span>`, "other fields untouched")
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// PostgresLogLineMatch matches the default log_line_prefix of "%m [%p] ",
+	// optionally followed by "%q%u@%d ".
+	PostgresLogLineMatch = regexp.MustCompile(`^(?P<ts>\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)?(?: \S+)?) \[(?P<pid>\d+)\] (?:(?P<user>\S*)@(?P<database>\S*) )?(?P<level>DEBUG[1-5]|LOG|INFO|NOTICE|WARNING|ERROR|FATAL|PANIC|DETAIL|HINT|QUERY|CONTEXT|LOCATION|STATEMENT):\s+(?P<msg>.*)$`)
+
+	// PostgresDurationMatch matches the duration that log_min_duration_statement
+	// and log_duration put at the start of the message.
+	PostgresDurationMatch = regexp.MustCompile(`^duration: (\d+(?:\.\d+)?) ms\s*`)
+
+	// MySQLLogLineMatch matches the MySQL 5.7 and 8.x error log, and MariaDB's,
+	// which has no error code or subsystem and a plain local timestamp.
+	MySQLLogLineMatch = regexp.MustCompile(`^(?P<ts>\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:\d{2})?) +(?P<thread>\d+) \[(?P<level>System|Note|Warning|ERROR|Error)\](?: \[(?P<errCode>MY-\d+)\])?(?: \[(?P<subsystem>[A-Za-z]+)\])? (?P<msg>.*)$`)
+
+	// RedisLogLineMatch matches the Redis server log.
+	RedisLogLineMatch = regexp.MustCompile(`^(?P<pid>\d+):(?P<role>[XCSM]) (?P<ts>\d{1,2} [A-Z][a-z]{2} \d{4} \d{2}:\d{2}:\d{2}\.\d{3}) (?P<level>[.\-*#]) (?P<msg>.*)$`)
+)
+
+const (
+	PostgresTime       = "2006-01-02 15:04:05.999 MST"
+	PostgresTimeOffset = "2006-01-02 15:04:05.999 -07"
+	MySQLTime          = "2006-01-02T15:04:05.999999Z07:00"
+	DateTimeNoZone     = "2006-01-02 15:04:05"
+	RedisTime          = "2 Jan 2006 15:04:05.000"
+)
+
+// redisLevels names the levels of the Redis log, which marks them with a single
+// character.
+var redisLevels = map[string]string{
+	".": "debug",
+	"-": "verbose",
+	"*": "notice",
+	"#": "warning",
+}
+
+// redisRoles names the process roles of the Redis log.
+var redisRoles = map[string]string{
+	"X": "sentinel",
+	"C": "child",
+	"S": "replica",
+	"M": "master",
+}
+
+// submatches matches line against the regular expression and returns the named
+// subexpressions that matched, or nil if the line does not match.
+func submatches(re *regexp.Regexp, line []byte) map[string]string {
+	sm := re.FindSubmatch(line)
+	if sm == nil {
+		return nil
+	}
+
+	res := make(map[string]string, len(sm))
+	for i, name := range re.SubexpNames() {
+		if name != "" && sm[i] != nil {
+			res[name] = string(sm[i])
+		}
+	}
+
+	return res
+}
+
+// parseServerTime parses a timestamp in the first of the given formats that
+// fits, returning the zero time if none do.
+func parseServerTime(ts string, formats ...string) time.Time {
+	for _, tfmt := range formats {
+		if t, err := time.Parse(tfmt, ts); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parsePostgresTime parses the timestamp of the PostgreSQL log, which is
+// written with a zone abbreviation like CEST unless log_timezone is an offset.
+// An abbreviation other than UTC or GMT is only known in the zone it belongs
+// to, so one the local zone does not use is kept as text rather than read as
+// UTC.
+func parsePostgresTime(ts string) any {
+	if t, err := time.Parse(PostgresTimeOffset, ts); err == nil {
+		return t
+	}
+
+	if t, err := time.ParseInLocation(PostgresTime, ts, time.Local); err == nil {
+		name, _ := t.Zone()
+		if loc := t.Location(); loc != time.Local && loc != time.UTC && name != "GMT" {
+			return ts
+		}
+		return t
+	}
+
+	return parseServerTime(ts, DateTimeNoZone)
+}
+
+// parsePostgresLogLine parses the PostgreSQL server log. The duration of a
+// logged statement is moved out of the message into "duration_ms".
+func parsePostgresLogLine(line []byte, tsField string) (map[string]any, error) {
+	sm := submatches(PostgresLogLineMatch, line)
+	if sm == nil {
+		return nil, ErrUnparseable
+	}
+
+	level := sm["level"]
+	if strings.HasPrefix(level, "DEBUG") {
+		level = "debug"
+	}
+
+	msg := sm["msg"]
+	lineData := make(map[string]any, 8)
+	if dm := PostgresDurationMatch.FindStringSubmatchIndex(msg); dm != nil {
+		lineData["duration_ms"], _ = strconv.ParseFloat(msg[dm[2]:dm[3]], 64)
+		if msg[dm[1]:] != "" {
+			msg = msg[dm[1]:]
+		}
+	}
+
+	lineData[tsField] = parsePostgresTime(sm["ts"])
+	lineData["pid"], _ = strconv.Atoi(sm["pid"])
+	lineData[lvlField] = level
+	lineData[msgField] = msg
+	if user, ok := sm["user"]; ok && user != "" {
+		lineData["user"] = user
+	}
+	if database, ok := sm["database"]; ok && database != "" {
+		lineData["database"] = database
+	}
+
+	return lineData, nil
+}
+
+// parseMySQLLogLine parses the MySQL and MariaDB error log.
+func parseMySQLLogLine(line []byte, tsField string) (map[string]any, error) {
+	sm := submatches(MySQLLogLineMatch, line)
+	if sm == nil {
+		return nil, ErrUnparseable
+	}
+
+	lineData := make(map[string]any, 7)
+	lineData[tsField] = parseServerTime(sm["ts"], MySQLTime, DateTimeNoZone)
+	lineData["thread"], _ = strconv.Atoi(sm["thread"])
+	lineData[lvlField] = sm["level"]
+	lineData[msgField] = sm["msg"]
+	if errCode, ok := sm["errCode"]; ok {
+		lineData["err_code"] = errCode
+	}
+	if subsystem, ok := sm["subsystem"]; ok {
+		lineData["subsystem"] = subsystem
+	}
+
+	return lineData, nil
+}
+
+// parseRedisLogLine parses the Redis server log.
+func parseRedisLogLine(line []byte, tsField string) (map[string]any, error) {
+	sm := submatches(RedisLogLineMatch, line)
+	if sm == nil {
+		return nil, ErrUnparseable
+	}
+
+	lineData := make(map[string]any, 5)
+	lineData[tsField] = parseServerTime(sm["ts"], RedisTime)
+	lineData["pid"], _ = strconv.Atoi(sm["pid"])
+	lineData["role"] = redisRoles[sm["role"]]
+	lineData[lvlField] = redisLevels[sm["level"]]
+	lineData[msgField] = sm["msg"]
+
+	return lineData, nil
+}
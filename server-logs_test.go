@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePostgresLogLine(t *testing.T) {
+	lineData, err := parsePostgresLogLine([]byte(`2026-08-13 14:22:03.117 UTC [123] LOG:  duration: 12.5 ms  statement: SELECT 1`), "ts")
+	require.NoError(t, err, "postgres line parses")
+
+	assert.Equal(t, time.Date(2026, 8, 13, 14, 22, 3, 117_000_000, time.UTC), lineData["ts"].(time.Time).UTC(), "timestamp parsed")
+	assert.Equal(t, 123, lineData["pid"], "pid parsed")
+	assert.Equal(t, "LOG", lineData["level"], "level parsed")
+	assert.Equal(t, 12.5, lineData["duration_ms"], "duration is numeric")
+	assert.Equal(t, "statement: SELECT 1", lineData["msg"], "duration moved out of the message")
+
+	lineData, err = parsePostgresLogLine([]byte(`2026-08-13 14:22:03.117 +02 [7] app@orders DEBUG2:  duration: 3 ms`), "ts")
+	require.NoError(t, err, "postgres line with user and database parses")
+
+	assert.Equal(t, time.Date(2026, 8, 13, 12, 22, 3, 117_000_000, time.UTC), lineData["ts"].(time.Time).UTC(), "numeric zone honored")
+	assert.Equal(t, "debug", lineData["level"], "numbered debug levels are debug")
+	assert.Equal(t, "app", lineData["user"], "user parsed")
+	assert.Equal(t, "orders", lineData["database"], "database parsed")
+	assert.Equal(t, 3.0, lineData["duration_ms"], "integral duration is numeric")
+	assert.Equal(t, "duration: 3 ms", lineData["msg"], "message kept when it is only the duration")
+}
+
+// TestParsePostgresLogLineZoneName checks that a zone abbreviation is read in
+// the local zone, and that one it does not use is kept as text.
+func TestParsePostgresLogLineZoneName(t *testing.T) {
+	origLocal := time.Local
+	t.Cleanup(func() { time.Local = origLocal })
+	time.Local = time.FixedZone("CEST", 2*60*60)
+
+	lineData, err := parsePostgresLogLine([]byte(`2026-08-13 14:22:03.117 CEST [123] LOG:  checkpoint starting: time`), "ts")
+	require.NoError(t, err, "postgres line parses")
+	assert.Equal(t, time.Date(2026, 8, 13, 12, 22, 3, 117_000_000, time.UTC), lineData["ts"].(time.Time).UTC(), "local zone name honored")
+
+	lineData, err = parsePostgresLogLine([]byte(`2026-08-13 14:22:03.117 GMT [123] LOG:  checkpoint starting: time`), "ts")
+	require.NoError(t, err, "postgres line in GMT parses")
+	assert.Equal(t, time.Date(2026, 8, 13, 14, 22, 3, 117_000_000, time.UTC), lineData["ts"].(time.Time).UTC(), "GMT is UTC")
+
+	lineData, err = parsePostgresLogLine([]byte(`2026-08-13 05:22:03.117 PDT [123] LOG:  checkpoint starting: time`), "ts")
+	require.NoError(t, err, "postgres line in another zone parses")
+	assert.Equal(t, "2026-08-13 05:22:03.117 PDT", lineData["ts"], "unknown zone name kept as text")
+	assert.Equal(t, "2026-08-13 05:22:03.117 PDT", (&TimeDisplay{}).Format(lineData, "ts"), "text shown as logged")
+
+	out := &bytes.Buffer{}
+	NewJSONFormatter(out, "ts", "", nil).FormatEntry(lineData)
+	assert.Contains(t, out.String(), `"ts":"2026-08-13 05:22:03.117 PDT"`, "text written as logged")
+}
+
+func TestParseMySQLLogLine(t *testing.T) {
+	lineData, err := parseMySQLLogLine([]byte(`2026-08-13T14:22:03.117123Z 0 [Warning] [MY-010068] [Server] CA certificate ca.pem is self signed.`), "ts")
+	require.NoError(t, err, "mysql 8 line parses")
+
+	assert.Equal(t, time.Date(2026, 8, 13, 14, 22, 3, 117_123_000, time.UTC), lineData["ts"].(time.Time).UTC(), "timestamp parsed")
+	assert.Equal(t, 0, lineData["thread"], "thread parsed")
+	assert.Equal(t, "Warning", lineData["level"], "level parsed")
+	assert.Equal(t, "MY-010068", lineData["err_code"], "error code parsed")
+	assert.Equal(t, "Server", lineData["subsystem"], "subsystem parsed")
+	assert.Equal(t, "CA certificate ca.pem is self signed.", lineData["msg"], "message parsed")
+
+	lineData, err = parseMySQLLogLine([]byte(`2026-08-13 14:22:03 0 [Note] mariadbd: ready for connections.`), "ts")
+	require.NoError(t, err, "mariadb line parses")
+
+	assert.Equal(t, time.Date(2026, 8, 13, 14, 22, 3, 0, time.UTC), lineData["ts"], "plain timestamp parsed")
+	assert.Equal(t, "Note", lineData["level"], "level parsed")
+	assert.NotContains(t, lineData, "err_code", "no error code")
+	assert.Equal(t, "mariadbd: ready for connections.", lineData["msg"], "message parsed")
+}
+
+func TestParseRedisLogLine(t *testing.T) {
+	lineData, err := parseRedisLogLine([]byte(`1:M 13 Aug 2026 14:22:03.117 * Ready to accept connections tcp`), "ts")
+	require.NoError(t, err, "redis line parses")
+
+	assert.Equal(t, time.Date(2026, 8, 13, 14, 22, 3, 117_000_000, time.UTC), lineData["ts"], "timestamp parsed")
+	assert.Equal(t, 1, lineData["pid"], "pid parsed")
+	assert.Equal(t, "master", lineData["role"], "role named")
+	assert.Equal(t, "notice", lineData["level"], "level named")
+	assert.Equal(t, "Ready to accept connections tcp", lineData["msg"], "message parsed")
+
+	lineData, err = parseRedisLogLine([]byte(`27:S 13 Aug 2026 14:22:04.000 # Connection with master lost.`), "ts")
+	require.NoError(t, err, "redis replica line parses")
+	assert.Equal(t, "replica", lineData["role"], "role named")
+	assert.Equal(t, "warning", lineData["level"], "level named")
+}
+
+// TestServerLogParsersRejectOthers makes sure each server log parser rejects
+// the other formats and plain text.
+func TestServerLogParsersRejectOthers(t *testing.T) {
+	parsers := map[string]LineParser{
+		"postgres": parsePostgresLogLine,
+		"mysql":    parseMySQLLogLine,
+		"redis":    parseRedisLogLine,
+	}
+
+	lines := map[string]string{
+		"postgres": `2026-08-13 14:22:03.117 UTC [123] LOG:  database system is ready to accept connections`,
+		"mysql":    `2026-08-13T14:22:03.117123Z 0 [System] [MY-010931] [Server] ready for connections.`,
+		"redis":    `1:M 13 Aug 2026 14:22:03.117 * Ready to accept connections tcp`,
+		"plain":    `Starting up: no JSON here, just a plain line`,
+	}
+
+	for parserName, parser := range parsers {
+		for lineName, line := range lines {
+			_, err := parser([]byte(line), "ts")
+			if parserName == lineName {
+				assert.NoError(t, err, "%s parses its own format", parserName)
+			} else {
+				assert.ErrorIs(t, err, ErrUnparseable, "%s rejects %s", parserName, lineName)
+			}
+		}
+	}
+}
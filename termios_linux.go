@@ -0,0 +1,9 @@
+package main
+
+import "golang.org/x/sys/unix"
+
+// These are the ioctls that get and set the terminal attributes.
+const (
+	ioctlGetTermios = unix.TCGETS
+	ioctlSetTermios = unix.TCSETS
+)
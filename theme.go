@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	gc "image/color"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Themes are the built-in palettes, selected by name with --theme. The colors
+// in the configuration are layered on top of the theme.
+var Themes = map[string]Palette{
+	"dark":            DefaultPalette,
+	"light":           LightPalette,
+	"solarized-dark":  solarizedPalette(solarizedBase0, solarizedBase1, solarizedBase01),
+	"solarized-light": solarizedPalette(solarizedBase00, solarizedBase02, solarizedBase1),
+	"high-contrast":   HighContrastPalette,
+	"colorblind":      ColorblindPalette,
+}
+
+// themeNames are the accepted --theme values, in the order they are listed in
+// help. An empty value means the theme was never set, which behaves as dark.
+var themeNames = []string{"dark", "light", "solarized-dark", "solarized-light", "high-contrast", "colorblind", "auto"}
+
+// LightPalette is tuned for terminals with a light background, with dark text
+// and deeper versions of the colors of the default palette.
+var LightPalette = Palette{
+	ColorNormal:        Fg(0x30, 0x30, 0x30),
+	ColorDateTime:      Fg(0x50, 0x50, 0x50),
+	ColorLevelDebug:    Fg(0x3a, 0x3a, 0xd0),
+	ColorLevelInfo:     Fg(0x00, 0x7a, 0x8a),
+	ColorLevelWarn:     Fg(0x9a, 0x6a, 0x00),
+	ColorLevelError:    Fg(0xb3, 0x59, 0x00),
+	ColorLevelDPanic:   Fg(0xc0, 0x30, 0x00),
+	ColorLevelFatal:    Fg(0xc0, 0x00, 0x00),
+	ColorMessage:       Fg(0x00, 0x00, 0x00),
+	ColorStackTrace:    Fg(0x80, 0x80, 0x80),
+	ColorData:          Fg(0x60, 0x60, 0x60),
+	ColorDataKey:       Fg(0x2a, 0x5d, 0x9a),
+	ColorDataString:    Fg(0x3a, 0x7a, 0x3a),
+	ColorDataNumber:    Fg(0x8a, 0x5a, 0x00),
+	ColorDataBool:      Fg(0x7a, 0x3a, 0xa0),
+	ColorDataNull:      Fg(0x90, 0x90, 0x90),
+	ColorDataPunct:     Fg(0x70, 0x70, 0x70),
+	ColorWorryInfo:     Fg(0x3a, 0x3a, 0xd0),
+	ColorWorryError:    Fg(0xb3, 0x59, 0x00),
+	ColorWorryWarn:     Fg(0x9a, 0x6a, 0x00),
+	ColorWorryCritical: Fg(0xc0, 0x30, 0x00),
+	ColorExtracted:     Fg(0x00, 0x70, 0x70),
+	ColorLogger:        Fg(0x50, 0x50, 0xa0),
+	ColorCaller:        Fg(0x80, 0x80, 0x80),
+	IdentityColors[0]:  Fg(0xc0, 0x39, 0x2b),
+	IdentityColors[1]:  Fg(0x00, 0x83, 0x8f),
+	IdentityColors[2]:  Fg(0xa0, 0x60, 0x00),
+	IdentityColors[3]:  Fg(0x39, 0x49, 0xab),
+	IdentityColors[4]:  Fg(0x2e, 0x7d, 0x32),
+	IdentityColors[5]:  Fg(0xad, 0x14, 0x57),
+	IdentityColors[6]:  Fg(0xd3, 0x54, 0x00),
+	IdentityColors[7]:  Fg(0x15, 0x65, 0xc0),
+	IdentityColors[8]:  Fg(0x6d, 0x8b, 0x00),
+	IdentityColors[9]:  Fg(0x6a, 0x1b, 0x9a),
+	IdentityColors[10]: Fg(0x00, 0x89, 0x7b),
+	IdentityColors[11]: Fg(0xc2, 0x18, 0x5b),
+}
+
+// HighContrastPalette is for dark terminals, using only bright, saturated
+// colors and white, so nothing is shown in gray.
+var HighContrastPalette = Palette{
+	ColorNormal:        Fg(0xff, 0xff, 0xff),
+	ColorDateTime:      Fg(0xff, 0xff, 0xff),
+	ColorLevelDebug:    Fg(0x87, 0xaf, 0xff),
+	ColorLevelInfo:     Fg(0x00, 0xff, 0xff),
+	ColorLevelWarn:     Fg(0xff, 0xff, 0x00),
+	ColorLevelError:    Fg(0xff, 0x87, 0x00),
+	ColorLevelDPanic:   Fg(0xff, 0x5f, 0x00),
+	ColorLevelFatal:    Fg(0xff, 0x00, 0x00),
+	ColorMessage:       Fg(0xff, 0xff, 0xff),
+	ColorStackTrace:    Fg(0xd0, 0xd0, 0xd0),
+	ColorData:          Fg(0xe4, 0xe4, 0xe4),
+	ColorDataKey:       Fg(0x87, 0xd7, 0xff),
+	ColorDataString:    Fg(0x87, 0xff, 0x87),
+	ColorDataNumber:    Fg(0xff, 0xd7, 0x87),
+	ColorDataBool:      Fg(0xff, 0x87, 0xff),
+	ColorDataNull:      Fg(0xd0, 0xd0, 0xd0),
+	ColorDataPunct:     Fg(0xe4, 0xe4, 0xe4),
+	ColorWorryInfo:     Fg(0x87, 0xaf, 0xff),
+	ColorWorryError:    Fg(0xff, 0x87, 0x00),
+	ColorWorryWarn:     Fg(0xff, 0xff, 0x00),
+	ColorWorryCritical: Fg(0xff, 0x5f, 0x00),
+	ColorExtracted:     Fg(0x00, 0xff, 0xff),
+	ColorLogger:        Fg(0xd7, 0xd7, 0xff),
+	ColorCaller:        Fg(0xd0, 0xd0, 0xd0),
+	IdentityColors[0]:  Fg(0xff, 0x87, 0x87),
+	IdentityColors[1]:  Fg(0x5f, 0xff, 0xff),
+	IdentityColors[2]:  Fg(0xff, 0xd7, 0x5f),
+	IdentityColors[3]:  Fg(0x87, 0x87, 0xff),
+	IdentityColors[4]:  Fg(0x87, 0xff, 0x87),
+	IdentityColors[5]:  Fg(0xff, 0x87, 0xff),
+	IdentityColors[6]:  Fg(0xff, 0xaf, 0x5f),
+	IdentityColors[7]:  Fg(0x5f, 0xaf, 0xff),
+	IdentityColors[8]:  Fg(0xd7, 0xff, 0x5f),
+	IdentityColors[9]:  Fg(0xaf, 0x87, 0xff),
+	IdentityColors[10]: Fg(0x5f, 0xff, 0xaf),
+	IdentityColors[11]: Fg(0xff, 0x87, 0xaf),
+}
+
+// ColorblindPalette is for dark terminals and built on the Okabe-Ito colors,
+// which stay distinct with the common forms of color blindness. Severity runs
+// from blue through yellow and orange to vermillion, never relying on telling
+// red from green.
+var ColorblindPalette = Palette{
+	ColorNormal:        Fg(0xdd, 0xdd, 0xdd),
+	ColorDateTime:      Fg(0xdd, 0xdd, 0xdd),
+	ColorLevelDebug:    Fg(0x56, 0xb4, 0xe9),
+	ColorLevelInfo:     Fg(0x00, 0x9e, 0x73),
+	ColorLevelWarn:     Fg(0xf0, 0xe4, 0x42),
+	ColorLevelError:    Fg(0xe6, 0x9f, 0x00),
+	ColorLevelDPanic:   Fg(0xd5, 0x5e, 0x00),
+	ColorLevelFatal:    Fg(0xd5, 0x5e, 0x00),
+	ColorMessage:       Fg(0xff, 0xff, 0xff),
+	ColorStackTrace:    Fg(0x88, 0x88, 0x88),
+	ColorData:          Fg(0xaa, 0xaa, 0xaa),
+	ColorDataKey:       Fg(0x56, 0xb4, 0xe9),
+	ColorDataString:    Fg(0x00, 0x9e, 0x73),
+	ColorDataNumber:    Fg(0xe6, 0x9f, 0x00),
+	ColorDataBool:      Fg(0xcc, 0x79, 0xa7),
+	ColorDataNull:      Fg(0x88, 0x88, 0x88),
+	ColorDataPunct:     Fg(0xaa, 0xaa, 0xaa),
+	ColorWorryInfo:     Fg(0x56, 0xb4, 0xe9),
+	ColorWorryError:    Fg(0xe6, 0x9f, 0x00),
+	ColorWorryWarn:     Fg(0xf0, 0xe4, 0x42),
+	ColorWorryCritical: Fg(0xd5, 0x5e, 0x00),
+	ColorExtracted:     Fg(0x56, 0xb4, 0xe9),
+	ColorLogger:        Fg(0xcc, 0x79, 0xa7),
+	ColorCaller:        Fg(0x88, 0x88, 0x88),
+	IdentityColors[0]:  Fg(0xe6, 0x9f, 0x00),
+	IdentityColors[1]:  Fg(0x56, 0xb4, 0xe9),
+	IdentityColors[2]:  Fg(0x00, 0x9e, 0x73),
+	IdentityColors[3]:  Fg(0xf0, 0xe4, 0x42),
+	IdentityColors[4]:  Fg(0x33, 0x8e, 0xd8),
+	IdentityColors[5]:  Fg(0xd5, 0x5e, 0x00),
+	IdentityColors[6]:  Fg(0xcc, 0x79, 0xa7),
+	IdentityColors[7]:  Fg(0xff, 0xc8, 0x66),
+	IdentityColors[8]:  Fg(0xa0, 0xd8, 0xf5),
+	IdentityColors[9]:  Fg(0x4d, 0xd4, 0xa8),
+	IdentityColors[10]: Fg(0xff, 0x99, 0x4d),
+	IdentityColors[11]: Fg(0xe8, 0xb0, 0xd0),
+}
+
+// The Solarized base tones, from darkest to lightest.
+var (
+	solarizedBase02  = Fg(0x07, 0x36, 0x42)
+	solarizedBase01  = Fg(0x58, 0x6e, 0x75)
+	solarizedBase00  = Fg(0x65, 0x7b, 0x83)
+	solarizedBase0   = Fg(0x83, 0x94, 0x96)
+	solarizedBase1   = Fg(0x93, 0xa1, 0xa1)
+	solarizedYellow  = Fg(0xb5, 0x89, 0x00)
+	solarizedOrange  = Fg(0xcb, 0x4b, 0x16)
+	solarizedRed     = Fg(0xdc, 0x32, 0x2f)
+	solarizedMagenta = Fg(0xd3, 0x36, 0x82)
+	solarizedViolet  = Fg(0x6c, 0x71, 0xc4)
+	solarizedBlue    = Fg(0x26, 0x8b, 0xd2)
+	solarizedCyan    = Fg(0x2a, 0xa1, 0x98)
+	solarizedGreen   = Fg(0x85, 0x99, 0x00)
+)
+
+// solarizedPalette builds a Solarized palette. The accent colors are the same
+// on dark and light backgrounds, so only the tones for body text, emphasized
+// text, and secondary text differ.
+func solarizedPalette(body, emphasis, secondary Style) Palette {
+	return Palette{
+		ColorNormal:        body,
+		ColorDateTime:      body,
+		ColorLevelDebug:    solarizedViolet,
+		ColorLevelInfo:     solarizedCyan,
+		ColorLevelWarn:     solarizedYellow,
+		ColorLevelError:    solarizedOrange,
+		ColorLevelDPanic:   solarizedRed,
+		ColorLevelFatal:    solarizedRed,
+		ColorMessage:       emphasis,
+		ColorStackTrace:    secondary,
+		ColorData:          body,
+		ColorDataKey:       solarizedBlue,
+		ColorDataString:    solarizedGreen,
+		ColorDataNumber:    solarizedMagenta,
+		ColorDataBool:      solarizedViolet,
+		ColorDataNull:      secondary,
+		ColorDataPunct:     secondary,
+		ColorWorryInfo:     solarizedBlue,
+		ColorWorryError:    solarizedOrange,
+		ColorWorryWarn:     solarizedYellow,
+		ColorWorryCritical: solarizedRed,
+		ColorExtracted:     solarizedCyan,
+		ColorLogger:        solarizedViolet,
+		ColorCaller:        secondary,
+		IdentityColors[0]:  solarizedYellow,
+		IdentityColors[1]:  solarizedOrange,
+		IdentityColors[2]:  solarizedRed,
+		IdentityColors[3]:  solarizedMagenta,
+		IdentityColors[4]:  solarizedViolet,
+		IdentityColors[5]:  solarizedBlue,
+		IdentityColors[6]:  solarizedCyan,
+		IdentityColors[7]:  solarizedGreen,
+		IdentityColors[8]:  Fg(0xd3, 0xa5, 0x2a),
+		IdentityColors[9]:  Fg(0xe0, 0x7a, 0x52),
+		IdentityColors[10]: Fg(0x8f, 0x94, 0xe0),
+		IdentityColors[11]: Fg(0x52, 0xb8, 0xb0),
+	}
+}
+
+// checkTheme rejects an unknown --theme value.
+func checkTheme() error {
+	if _, ok := Themes[theme]; ok || theme == "" || theme == "auto" {
+		return nil
+	}
+	return fmt.Errorf("invalid --theme %q: expected one of %s", theme, strings.Join(themeNames, ", "))
+}
+
+// setupTheme picks the palette of the theme named by --theme. In auto mode,
+// the dark or light palette is picked to suit the background of the terminal.
+func setupTheme(out io.Writer) Palette {
+	if theme == "auto" {
+		if detectLightBackground(os.Getenv, out) {
+			return LightPalette
+		}
+		return DefaultPalette
+	}
+
+	if p, ok := Themes[theme]; ok {
+		return p
+	}
+	return DefaultPalette
+}
+
+// detectLightBackground reports whether the terminal out writes to has a light
+// background. It asks the terminal for its background color with an OSC 11
+// query, falling back to COLORFGBG, which some terminals set to the foreground
+// and background colors, like "15;0". If neither answers, the background is
+// taken to be dark.
+func detectLightBackground(getenv func(string) string, out io.Writer) bool {
+	if isTerminal(out) {
+		if bg, ok := queryBackground(200 * time.Millisecond); ok {
+			return isLightColor(bg)
+		}
+	}
+
+	light, _ := parseColorFGBG(getenv("COLORFGBG"))
+	return light
+}
+
+// osc11 matches the answer to an OSC 11 query, which gives the background as
+// hexadecimal channels of one to four digits each.
+var osc11 = regexp.MustCompile(`\x1b\]11;rgba?:([0-9a-fA-F]{1,4})/([0-9a-fA-F]{1,4})/([0-9a-fA-F]{1,4})`)
+
+// parseOSC11 finds the background color in the answer to an OSC 11 query.
+func parseOSC11(resp []byte) (gc.Color, bool) {
+	m := osc11.FindSubmatch(resp)
+	if m == nil {
+		return nil, false
+	}
+
+	var rgb [3]uint8
+	for i, ch := range m[1:4] {
+		v, err := strconv.ParseUint(string(ch), 16, 16)
+		if err != nil {
+			return nil, false
+		}
+
+		// Scale the channel from however many digits it has to 8 bits.
+		maxV := uint64(1)<<(4*len(ch)) - 1
+		rgb[i] = uint8(v * 255 / maxV)
+	}
+	return RGB(rgb[0], rgb[1], rgb[2]), true
+}
+
+// parseColorFGBG reads the background from COLORFGBG, which holds the
+// foreground and background as ANSI color numbers, like "15;0", sometimes with
+// a field in between. White, light gray, and the bright colors other than dark
+// gray are light. It reports false if the background cannot be read.
+func parseColorFGBG(s string) (light, ok bool) {
+	fields := strings.Split(s, ";")
+	if len(fields) < 2 {
+		return false, false
+	}
+
+	bg, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil {
+		return false, false
+	}
+	return bg == 7 || (bg > 8 && bg <= 15), true
+}
+
+// isLightColor reports whether c is light enough that dark text on it is
+// easier to read than light text.
+func isLightColor(c gc.Color) bool {
+	r, g, b := rgb8(c)
+	return 299*int(r)+587*int(g)+114*int(b) > 128*1000
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestThemesComplete checks that every theme sets every color of the default
+// palette, so no part of the output falls back to the normal color.
+func TestThemesComplete(t *testing.T) {
+	for name, p := range Themes {
+		for cn := range DefaultPalette {
+			assert.Contains(t, p, cn, "theme %s sets %s", name, cn)
+		}
+		assert.Contains(t, themeNames, name, "theme %s is listed", name)
+	}
+}
+
+// TestThemeBackgrounds checks that the light themes have dark text and the
+// dark themes have light text.
+func TestThemeBackgrounds(t *testing.T) {
+	for name, light := range map[string]bool{
+		"dark":            false,
+		"light":           true,
+		"solarized-dark":  false,
+		"solarized-light": true,
+		"high-contrast":   false,
+		"colorblind":      false,
+	} {
+		assert.Equal(t, light, !isLightColor(Themes[name][ColorNormal].FG), "theme %s is for a light background", name)
+	}
+}
+
+// TestCheckTheme covers the accepted --theme values.
+func TestCheckTheme(t *testing.T) {
+	orig := theme
+	t.Cleanup(func() { theme = orig })
+
+	for _, name := range append(themeNames, "") {
+		theme = name
+		assert.NoError(t, checkTheme(), "%q is valid", name)
+	}
+
+	theme = "solarised"
+	err := checkTheme()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "solarized-dark", "error lists the themes")
+}
+
+// TestSetupPaletteTheme checks that the configured colors are layered on top
+// of the theme.
+func TestSetupPaletteTheme(t *testing.T) {
+	origTheme, origConfig := theme, config
+	t.Cleanup(func() { theme, config = origTheme, origConfig })
+
+	theme = "light"
+	config = DefaultConfig()
+	config.Colors = map[string]string{"level-error": "#ff0000"}
+
+	p := setupPalette(&bytes.Buffer{})
+
+	assert.Equal(t, Fg(0xff, 0x00, 0x00), p[ColorLevelError], "configured color wins")
+	assert.Equal(t, LightPalette[ColorMessage], p[ColorMessage], "theme color otherwise")
+
+	// Not a terminal and no COLORFGBG, so auto picks dark.
+	t.Setenv("COLORFGBG", "")
+	theme = "auto"
+	p = setupPalette(&bytes.Buffer{})
+	assert.Equal(t, DefaultPalette[ColorMessage], p[ColorMessage], "auto without a terminal")
+
+	t.Setenv("COLORFGBG", "0;15")
+	p = setupPalette(&bytes.Buffer{})
+	assert.Equal(t, LightPalette[ColorMessage], p[ColorMessage], "auto with a light COLORFGBG")
+}
+
+// TestParseOSC11 covers the forms terminals answer an OSC 11 query in.
+func TestParseOSC11(t *testing.T) {
+	tests := []struct {
+		resp  string
+		want  [3]uint8
+		light bool
+	}{
+		{"\x1b]11;rgb:ffff/ffff/ffff\x1b\\\x1b[?62;22c", [3]uint8{0xff, 0xff, 0xff}, true},
+		{"\x1b]11;rgb:1e1e/1e1e/1e1e\x07", [3]uint8{0x1e, 0x1e, 0x1e}, false},
+		{"\x1b]11;rgb:fd/f6/e3\x1b\\", [3]uint8{0xfd, 0xf6, 0xe3}, true},
+		{"\x1b]11;rgba:0000/2b2b/3636/ffff\x1b\\", [3]uint8{0x00, 0x2b, 0x36}, false},
+		{"\x1b]11;rgb:f/f/f\x07", [3]uint8{0xff, 0xff, 0xff}, true},
+	}
+
+	for _, test := range tests {
+		c, ok := parseOSC11([]byte(test.resp))
+		require.True(t, ok, "parsed %q", test.resp)
+		assert.Equal(t, RGB(test.want[0], test.want[1], test.want[2]), c, "color of %q", test.resp)
+		assert.Equal(t, test.light, isLightColor(c), "lightness of %q", test.resp)
+	}
+
+	_, ok := parseOSC11([]byte("\x1b[?62;22c"))
+	assert.False(t, ok, "only device attributes: the terminal ignored the query")
+}
+
+// TestParseColorFGBG covers the forms of COLORFGBG.
+func TestParseColorFGBG(t *testing.T) {
+	tests := []struct {
+		value     string
+		light, ok bool
+	}{
+		{"15;0", false, true},
+		{"0;15", true, true},
+		{"0;7", true, true},
+		{"7;8", false, true},
+		{"15;default;0", false, true},
+		{"0;default;15", true, true},
+		{"", false, false},
+		{"15", false, false},
+		{"15;default", false, false},
+	}
+
+	for _, test := range tests {
+		light, ok := parseColorFGBG(test.value)
+		assert.Equal(t, test.ok, ok, "%q is readable", test.value)
+		assert.Equal(t, test.light, light, "%q is light", test.value)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// timeFormatPresets are the named --time-format values. Anything else is used
+// as a strftime pattern if it contains a %, or as a Go layout otherwise.
+var timeFormatPresets = map[string]string{
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"short":       "2006-01-02 15:04:05",
+	"time-only":   "15:04:05.000",
+	"kitchen":     time.Kitchen,
+	"stamp":       time.StampMilli,
+}
+
+// strftimeLayouts maps strftime conversions to the equivalent Go layout.
+var strftimeLayouts = map[byte]string{
+	'Y': "2006",
+	'y': "06",
+	'm': "01",
+	'b': "Jan",
+	'B': "January",
+	'd': "02",
+	'e': "_2",
+	'j': "002",
+	'a': "Mon",
+	'A': "Monday",
+	'H': "15",
+	'I': "03",
+	'M': "04",
+	'S': "05",
+	'L': ".000",
+	'f': ".000000",
+	'p': "PM",
+	'z': "-0700",
+	'Z': "MST",
+	'F': "2006-01-02",
+	'T': "15:04:05",
+	'%': "%",
+}
+
+// relativeModes are the accepted --relative values. An empty value, or "off",
+// shows the timestamps themselves.
+var relativeModes = []string{"off", "first", "prev"}
+
+// missingTimestamp is shown in place of a missing timestamp when the timestamp
+// is displayed as logged.
+const missingTimestamp = "0000-00-00T00:00:00.000000-00:00"
+
+// TimeDisplay renders the timestamps of log entries for the human-readable
+// output formats. It converts them to a time zone and layout, or shows the time
+// elapsed since the first entry or since the one before, which makes latency
+// gaps stand out. The zero value shows timestamps as logged.
+type TimeDisplay struct {
+	loc      *time.Location
+	layout   string
+	relative string
+	since    time.Time
+}
+
+// NewTimeDisplay builds the time display for the --tz, --time-format, and
+// --relative values. An empty tz keeps the zone each timestamp was logged in,
+// and an empty format is RFC 3339 with nanoseconds.
+func NewTimeDisplay(tz, format, relative string) (*TimeDisplay, error) {
+	td := &TimeDisplay{}
+
+	switch strings.ToLower(tz) {
+	case "":
+	case "local":
+		td.loc = time.Local
+	case "utc":
+		td.loc = time.UTC
+	default:
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --tz %q: %v", tz, err)
+		}
+		td.loc = loc
+	}
+
+	td.layout = parseTimeFormat(format)
+
+	switch relative {
+	case "", "off":
+	case "first", "prev":
+		td.relative = relative
+	default:
+		return nil, fmt.Errorf("invalid --relative mode %q: expected one of %s", relative, strings.Join(relativeModes, ", "))
+	}
+
+	return td, nil
+}
+
+// parseTimeFormat turns a --time-format value into a Go layout.
+func parseTimeFormat(format string) string {
+	if format == "" {
+		return ""
+	}
+
+	if layout, ok := timeFormatPresets[strings.ToLower(format)]; ok {
+		return layout
+	}
+
+	if !strings.Contains(format, "%") {
+		return format
+	}
+
+	sb := &strings.Builder{}
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			sb.WriteByte(format[i])
+			continue
+		}
+
+		i++
+		if layout, ok := strftimeLayouts[format[i]]; ok {
+			sb.WriteString(layout)
+		} else {
+			sb.WriteByte('%')
+			sb.WriteByte(format[i])
+		}
+	}
+	return sb.String()
+}
+
+// Format renders the timestamp of the log entry. A missing timestamp is shown
+// as a placeholder as wide as a real one, so the rest of the line stays put. A
+// timestamp kept as text, because it could not be placed in time, is shown as
+// it was logged.
+func (td *TimeDisplay) Format(lineData map[string]any, tsField string) string {
+	if text, err := getString(lineData, tsField); err == nil && td.relative == "" {
+		return text
+	}
+
+	ts, err := getTime(lineData, tsField)
+	if err != nil || (td.relative != "" && ts.IsZero()) {
+		return td.placeholder()
+	}
+
+	if td.relative != "" {
+		if td.since.IsZero() {
+			td.since = ts
+		}
+
+		d := ts.Sub(td.since)
+		if td.relative == "prev" {
+			td.since = ts
+		}
+		return formatRelative(d)
+	}
+
+	if td.loc != nil {
+		ts = ts.In(td.loc)
+	}
+
+	layout := td.layout
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	return ts.Format(layout)
+}
+
+// MinWidth returns the narrowest a timestamp is shown in the layout and time
+// zone, which is what a column of them starts at before any are seen. A
+// fraction of a second trimmed of trailing zeros, a zone name, or an offset in
+// the zone each timestamp was logged in may show wider, which widens the column
+// when it is seen.
+func (td *TimeDisplay) MinWidth() int {
+	if td.relative != "" {
+		return utf8.RuneCountInString(formatRelative(0))
+	}
+
+	loc := td.loc
+	if loc == nil {
+		loc = time.UTC
+	}
+	layout := td.layout
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	return utf8.RuneCountInString(time.Date(2026, 5, 1, 1, 0, 0, 0, loc).Format(layout))
+}
+
+// placeholder renders the stand-in for a missing timestamp.
+func (td *TimeDisplay) placeholder() string {
+	switch {
+	case td.relative != "":
+		return strings.Repeat("-", utf8.RuneCountInString(formatRelative(0)))
+	case td.layout != "":
+		return strings.Repeat("-", utf8.RuneCountInString(time.Time{}.Format(td.layout)))
+	}
+	return missingTimestamp
+}
+
+// formatRelative renders an elapsed time as +H:MM:SS.mmm.
+func formatRelative(d time.Duration) string {
+	sign := "+"
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+
+	d = d.Truncate(time.Millisecond)
+	h := d / time.Hour
+	m := d % time.Hour / time.Minute
+	s := d % time.Minute / time.Second
+	ms := d % time.Second / time.Millisecond
+	return fmt.Sprintf("%s%d:%02d:%02d.%03d", sign, h, m, s, ms)
+}
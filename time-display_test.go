@@ -0,0 +1,113 @@
+package main
+
+import (
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTimeDisplayZoneAndFormat checks converting timestamps to another zone and
+// the named, strftime, and Go layout formats.
+func TestTimeDisplayZoneAndFormat(t *testing.T) {
+	lineData := map[string]any{"ts": time.Date(2026, 8, 13, 14, 22, 9, 482_000_000, time.UTC)}
+
+	tests := []struct {
+		tz, format, want string
+	}{
+		{"", "", "2026-08-13T14:22:09.482Z"},
+		{"America/Chicago", "", "2026-08-13T09:22:09.482-05:00"},
+		{"utc", "short", "2026-08-13 14:22:09"},
+		{"", "time-only", "14:22:09.482"},
+		{"", "kitchen", "2:22PM"},
+		{"", "%Y/%m/%d %H:%M:%S%L %%", "2026/08/13 14:22:09.482 %"},
+		{"", "Jan 2 15:04", "Aug 13 14:22"},
+	}
+
+	for _, test := range tests {
+		td, err := NewTimeDisplay(test.tz, test.format, "")
+		require.NoError(t, err, "tz %q format %q", test.tz, test.format)
+
+		assert.Equal(t, test.want, td.Format(lineData, "ts"), "tz %q format %q", test.tz, test.format)
+	}
+}
+
+// TestTimeDisplayRelative checks the time since the first line and since the
+// previous line, and that lines without a timestamp are skipped over.
+func TestTimeDisplayRelative(t *testing.T) {
+	start := time.Date(2026, 8, 13, 14, 22, 9, 0, time.UTC)
+	lines := []map[string]any{
+		{"ts": start},
+		{"ts": start.Add(1500 * time.Millisecond)},
+		{"msg": "no timestamp"},
+		{"ts": start.Add(time.Hour + 2*time.Minute + 3*time.Second)},
+	}
+
+	render := func(mode string) []string {
+		td, err := NewTimeDisplay("", "", mode)
+		require.NoError(t, err, "mode %q", mode)
+
+		var got []string
+		for _, lineData := range lines {
+			got = append(got, td.Format(lineData, "ts"))
+		}
+		return got
+	}
+
+	assert.Equal(t, []string{"+0:00:00.000", "+0:00:01.500", "------------", "+1:02:03.000"}, render("first"), "since the first line")
+	assert.Equal(t, []string{"+0:00:00.000", "+0:00:01.500", "------------", "+1:02:01.500"}, render("prev"), "since the previous line")
+}
+
+// TestTimeDisplayMissing checks the placeholders for a missing timestamp.
+func TestTimeDisplayMissing(t *testing.T) {
+	td := &TimeDisplay{}
+	assert.Equal(t, missingTimestamp, td.Format(map[string]any{}, "ts"), "as logged")
+
+	td, err := NewTimeDisplay("", "time-only", "")
+	require.NoError(t, err, "time-only")
+	assert.Equal(t, "------------", td.Format(map[string]any{}, "ts"), "as wide as the format")
+}
+
+// TestNewTimeDisplayInvalid checks that bad zones and modes are rejected.
+func TestNewTimeDisplayInvalid(t *testing.T) {
+	_, err := NewTimeDisplay("Mars/Olympus_Mons", "", "")
+	assert.ErrorContains(t, err, "invalid --tz", "unknown zone")
+
+	_, err = NewTimeDisplay("", "", "last")
+	assert.ErrorContains(t, err, "first, prev", "unknown relative mode")
+}
+
+// TestTimeDisplayMinWidth checks that no timestamp is shown narrower than
+// MinWidth, and that it is the width of a layout that shows every timestamp
+// the same, in the zone they are shown in.
+func TestTimeDisplayMinWidth(t *testing.T) {
+	stamps := []time.Time{
+		time.Date(2026, 9, 30, 14, 22, 9, 0, time.UTC),
+		time.Date(2026, 9, 30, 4, 2, 9, 482_123_456, time.FixedZone("", -5*60*60)),
+		time.Date(2026, 5, 3, 1, 2, 3, 0, time.FixedZone("", (5*60+30)*60)),
+	}
+
+	for _, tt := range []struct {
+		tz, format, relative string
+		width                int
+	}{
+		{"", "", "", 20},
+		{"UTC", "rfc3339nano", "", 20},
+		{"", "Monday, January 2 2006 .000", "", 23},
+		{"", "%H:%M:%S", "", 8},
+		{"UTC", "2006-01-02T15:04:05.000Z07:00", "", 24},
+		{"", "", "first", 12},
+	} {
+		td, err := NewTimeDisplay(tt.tz, tt.format, tt.relative)
+		require.NoError(t, err)
+
+		width := td.MinWidth()
+		assert.Equal(t, tt.width, width, "width of %q", tt.format)
+		for _, ts := range stamps {
+			shown := td.Format(map[string]any{"ts": ts}, "ts")
+			assert.GreaterOrEqual(t, utf8.RuneCountInString(shown), width, "%q is at least %d wide", shown, width)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// WorryMatcher finds the worry words in text. Each worry is a word, a phrase of
+// several words, or a regular expression written between slashes, like
+// /5\d\d/. All of them match case-insensitively and only whole words: a match
+// never starts or ends in the middle of a word.
+//
+// When matches overlap, the one starting first wins, and of those starting at
+// the same place, the longest, then the most severe. So with "connection
+// refused" at err and "connection" at info, "connection refused" is
+// highlighted as a whole at err.
+//
+// The words and phrases are all found in a single pass over the text, however
+// many there are. Each regular expression takes a pass of its own, so long
+// lists are better written as words.
+type WorryMatcher struct {
+	words    worryAutomaton
+	patterns []worryPattern
+}
+
+// worryPattern is a regular expression.
+type worryPattern struct {
+	re       *regexp.Regexp
+	severity WorrySeverity
+}
+
+// worries is the matcher for WorryWords, compiled by setupWorries.
+var worries = mustNewWorryMatcher(WorryWords)
+
+// NewWorryMatcher compiles the worry words, phrases, and patterns. Those with
+// severity WorryNone are left out.
+func NewWorryMatcher(words map[string]WorrySeverity) (*WorryMatcher, error) {
+	m := &WorryMatcher{words: newWorryAutomaton()}
+	for word, sev := range words {
+		if sev == WorryNone {
+			continue
+		}
+
+		if len(word) > 2 && strings.HasPrefix(word, "/") && strings.HasSuffix(word, "/") {
+			re, err := regexp.Compile("(?i)" + word[1:len(word)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid worry pattern %s: %w", word, err)
+			}
+			m.patterns = append(m.patterns, worryPattern{re, sev})
+			continue
+		}
+
+		if text := strings.ToLower(strings.TrimSpace(word)); text != "" {
+			m.words.add(text, sev)
+		}
+	}
+	m.words.link()
+
+	// Map iteration order is random, so sort the patterns to find the same
+	// matches every time.
+	slices.SortFunc(m.patterns, func(a, b worryPattern) int {
+		return strings.Compare(a.re.String(), b.re.String())
+	})
+
+	return m, nil
+}
+
+// mustNewWorryMatcher compiles the built-in worry words, which are known to be
+// valid.
+func mustNewWorryMatcher(words map[string]WorrySeverity) *WorryMatcher {
+	m, err := NewWorryMatcher(words)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Find returns the worry words in s, in order and without overlaps.
+func (m *WorryMatcher) Find(s string) []worrySpan {
+	found := m.words.find(s)
+
+	for _, p := range m.patterns {
+		for _, loc := range p.re.FindAllStringIndex(s, -1) {
+			if loc[1] > loc[0] && wholeWords(s, loc[0], loc[1]) {
+				found = append(found, worryMatch{loc[0], loc[1], p.severity})
+			}
+		}
+	}
+
+	return resolveWorries(found)
+}
+
+// worryMatch is a match before overlaps are resolved.
+type worryMatch struct {
+	start, end int
+	severity   WorrySeverity
+}
+
+// resolveWorries picks the matches to highlight from left to right, preferring
+// the longest and then the most severe of the matches starting at the same
+// place, and dropping any that overlap one already picked.
+func resolveWorries(found []worryMatch) []worrySpan {
+	if len(found) == 0 {
+		return nil
+	}
+
+	slices.SortFunc(found, func(a, b worryMatch) int {
+		return cmp.Or(
+			cmp.Compare(a.start, b.start),
+			cmp.Compare(b.end, a.end),
+			cmp.Compare(b.severity, a.severity),
+		)
+	})
+
+	spans := make([]worrySpan, 0, len(found))
+	last := 0
+	for _, f := range found {
+		if f.start < last {
+			continue
+		}
+		spans = append(spans, worrySpan{f.start, f.end, f.severity, worryLevelColors[f.severity]})
+		last = f.end
+	}
+	return spans
+}
+
+// wholeWords reports whether s[start:end] neither starts nor ends in the
+// middle of a word.
+func wholeWords(s string, start, end int) bool {
+	if start > 0 && wordBefore(s, start) && wordAt(s, start) {
+		return false
+	}
+	if end < len(s) && wordBefore(s, end) && wordAt(s, end) {
+		return false
+	}
+	return true
+}
+
+// wordAt reports whether s has a word character at i.
+func wordAt(s string, i int) bool {
+	if i >= len(s) {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return IsWordCharacter(r)
+}
+
+// wordBefore reports whether s has a word character just before i.
+func wordBefore(s string, i int) bool {
+	if i <= 0 {
+		return false
+	}
+	r, _ := utf8.DecodeLastRuneInString(s[:i])
+	return IsWordCharacter(r)
+}
+
+// denseFanout is the most edges a node has before link gives it a table of
+// its transitions by byte, so following an edge never scans more than this
+// many, however many words share the prefix.
+const denseFanout = 8
+
+// worryAutomaton is an Aho-Corasick automaton over the lowercase UTF-8 bytes of
+// the worry words and phrases. Each node is a prefix of one or more of them.
+type worryAutomaton struct {
+	nodes []worryNode
+
+	// root holds the transitions from the root for every byte, since most
+	// bytes of most text lead back to it.
+	root [256]int32
+}
+
+// worryNode is a node of the worryAutomaton.
+type worryNode struct {
+	edges []worryEdge
+
+	// dense holds the transitions of a node with more than denseFanout edges,
+	// by byte, with -1 where there is no edge. It is nil for other nodes.
+	dense *[256]int32
+
+	// fail is the node for the longest proper suffix of this prefix that is
+	// also a prefix, followed when there is no edge for the next byte.
+	fail int32
+
+	// output is the next node along the fail links that ends a word, or 0,
+	// the root, if none does.
+	output int32
+
+	// length is the length of the word that ends here, or 0 if none does.
+	length   int
+	severity WorrySeverity
+}
+
+// worryEdge is a transition from a node on a byte.
+type worryEdge struct {
+	b  byte
+	to int32
+}
+
+// newWorryAutomaton returns an automaton with only the root.
+func newWorryAutomaton() worryAutomaton {
+	return worryAutomaton{nodes: []worryNode{{}}}
+}
+
+// add adds a lowercase word to the automaton. Of words that are the same once
+// lowercased, the most severe is kept.
+func (a *worryAutomaton) add(word string, sev WorrySeverity) {
+	n := int32(0)
+	for i := 0; i < len(word); i++ {
+		next, ok := a.child(n, word[i])
+		if !ok {
+			next = int32(len(a.nodes))
+			a.nodes = append(a.nodes, worryNode{})
+			a.nodes[n].edges = append(a.nodes[n].edges, worryEdge{word[i], next})
+		}
+		n = next
+	}
+
+	a.nodes[n].length = len(word)
+	a.nodes[n].severity = max(a.nodes[n].severity, sev)
+}
+
+// link sets the fail and output links, breadth first so the links of shorter
+// prefixes are set first, and fills in the root transitions. It is called
+// after the last word is added.
+func (a *worryAutomaton) link() {
+	queue := []int32{0}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+
+		for _, e := range a.nodes[n].edges {
+			fail := int32(0)
+			for f := a.nodes[n].fail; n != 0; f = a.nodes[f].fail {
+				if next, ok := a.child(f, e.b); ok {
+					fail = next
+					break
+				}
+				if f == 0 {
+					break
+				}
+			}
+
+			child := &a.nodes[e.to]
+			child.fail = fail
+			child.output = a.nodes[fail].output
+			if a.nodes[fail].length > 0 {
+				child.output = fail
+			}
+			queue = append(queue, e.to)
+		}
+	}
+
+	for b := range a.root {
+		a.root[b], _ = a.child(0, byte(b))
+	}
+
+	for n := range a.nodes {
+		node := &a.nodes[n]
+		if len(node.edges) <= denseFanout {
+			continue
+		}
+
+		node.dense = new([256]int32)
+		for b := range node.dense {
+			node.dense[b] = -1
+		}
+		for _, e := range node.edges {
+			node.dense[e.b] = e.to
+		}
+	}
+}
+
+// child returns the node reached from n on b, if there is an edge for it.
+func (a *worryAutomaton) child(n int32, b byte) (int32, bool) {
+	node := &a.nodes[n]
+	if node.dense != nil {
+		to := node.dense[b]
+		return to, to >= 0
+	}
+	for _, e := range node.edges {
+		if e.b == b {
+			return e.to, true
+		}
+	}
+	return 0, false
+}
+
+// step returns the node reached from n on b, following fail links until there
+// is an edge for it.
+func (a *worryAutomaton) step(n int32, b byte) int32 {
+	for n != 0 {
+		if next, ok := a.child(n, b); ok {
+			return next
+		}
+		n = a.nodes[n].fail
+	}
+	return a.root[b]
+}
+
+// find returns the words and phrases in s that are whole words. It lowercases s
+// a rune at a time as it goes, and looks for the words ending after each rune.
+func (a *worryAutomaton) find(s string) []worryMatch {
+	var found []worryMatch
+	var lower [utf8.UTFMax]byte
+
+	n := int32(0)
+	for i := 0; i < len(s); {
+		r, size := rune(s[i]), 1
+		if r < utf8.RuneSelf {
+			if 'A' <= r && r <= 'Z' {
+				r += 'a' - 'A'
+			}
+			n = a.step(n, byte(r))
+		} else {
+			r, size = utf8.DecodeRuneInString(s[i:])
+			for _, b := range lower[:utf8.EncodeRune(lower[:], unicode.ToLower(r))] {
+				n = a.step(n, b)
+			}
+		}
+		i += size
+
+		out := n
+		if a.nodes[out].length == 0 {
+			out = a.nodes[out].output
+		}
+		for ; out != 0; out = a.nodes[out].output {
+			node := &a.nodes[out]
+			if start := lowerStart(s, i, node.length); start >= 0 && wholeWords(s, start, i) {
+				found = append(found, worryMatch{start, i, node.severity})
+			}
+		}
+	}
+	return found
+}
+
+// lowerStart returns where the text that is n bytes long once lowercased, and
+// ends at end, starts in s, or -1 if no rune of s starts there.
+func lowerStart(s string, end, n int) int {
+	for n > 0 && end > 0 {
+		r, size := utf8.DecodeLastRuneInString(s[:end])
+		if r < utf8.RuneSelf {
+			n--
+		} else {
+			n -= utf8.RuneLen(unicode.ToLower(r))
+		}
+		end -= size
+	}
+
+	if n != 0 {
+		return -1
+	}
+	return end
+}
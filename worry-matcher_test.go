@@ -0,0 +1,357 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// worryTexts lists the matches as "text:severity color", for comparing.
+func worryTexts(s string, spans []worrySpan) []string {
+	var got []string
+	for _, span := range spans {
+		got = append(got, s[span.start:span.end]+":"+string(span.color))
+	}
+	return got
+}
+
+// TestWorryMatcher covers words, phrases, and patterns, all matching whole
+// words only, in any case.
+func TestWorryMatcher(t *testing.T) {
+	m, err := NewWorryMatcher(map[string]WorrySeverity{
+		"failed":                    WorryErr,
+		"connection refused":        WorryErr,
+		"Context Deadline Exceeded": WorryErr,
+		"OOMKilled":                 WorryCrit,
+		`/5\d\d/`:                   WorryInfo,
+		`/retry(ing)? #\d+/`:        WorryWarn,
+		"ignored":                   WorryNone,
+	})
+	require.NoError(t, err)
+
+	tests := map[string][]string{
+		"dial: Connection Refused.":             {"Connection Refused:worry-err"},
+		"connection  refused":                   nil,
+		"context deadline exceeded after 5s":    {"context deadline exceeded:worry-err"},
+		"container OOMKILLED, failed":           {"OOMKILLED:worry-crit", "failed:worry-err"},
+		"status 503 and 15034 and 5000":         {"503:worry-info"},
+		"retrying #3 in 1s":                     {"retrying #3:worry-warn"},
+		"unfailed failedover ignored":           nil,
+		"reconnection refused":                  nil,
+		"connection refusedly":                  nil,
+		"é failed é":                            {"failed:worry-err"},
+		"":                                      nil,
+		"failed connection refused 500 failed":  {"failed:worry-err", "connection refused:worry-err", "500:worry-info", "failed:worry-err"},
+		"retry #1 failed; 502 upstream failed.": {"retry #1:worry-warn", "failed:worry-err", "502:worry-info", "failed:worry-err"},
+	}
+
+	for s, want := range tests {
+		assert.Equal(t, want, worryTexts(s, m.Find(s)), "worries in %q", s)
+	}
+}
+
+// TestWorryMatcherFanout checks matching through nodes with more edges than
+// are scanned, which are looked up in a table instead.
+func TestWorryMatcherFanout(t *testing.T) {
+	words := map[string]WorrySeverity{}
+	for _, c := range fanoutAlphabet {
+		words["e"+string(c)] = WorryWarn
+		words["e"+string(c)+"x"] = WorryErr
+	}
+	m, err := NewWorryMatcher(words)
+	require.NoError(t, err)
+
+	tests := map[string][]string{
+		"ea ez e0 e9":  {"ea:worry-warn", "ez:worry-warn", "e0:worry-warn", "e9:worry-warn"},
+		"EQX eqy eq_":  {"EQX:worry-err"},
+		"é e- e ee5":   nil,
+		"ebx, e_ e7x.": {"ebx:worry-err", "e7x:worry-err"},
+	}
+
+	for s, want := range tests {
+		assert.Equal(t, want, worryTexts(s, m.Find(s)), "worries in %q", s)
+	}
+}
+
+// TestWorryMatcherOverlap checks the precedence of overlapping matches: the
+// first to start wins, then the longest, then the most severe.
+func TestWorryMatcherOverlap(t *testing.T) {
+	m, err := NewWorryMatcher(map[string]WorrySeverity{
+		"connection":         WorryInfo,
+		"connection refused": WorryErr,
+		"refused":            WorryCrit,
+		"/refused by \\w+/":  WorryWarn,
+		"timeout":            WorryWarn,
+		"/time\\w*/":         WorryCrit,
+	})
+	require.NoError(t, err)
+
+	tests := map[string][]string{
+		"connection refused by peer": {"connection refused:worry-err"},
+		"refused by peer":            {"refused by peer:worry-warn"},
+		"connection reset":           {"connection:worry-info"},
+		"timeout":                    {"timeout:worry-crit"},
+	}
+
+	for s, want := range tests {
+		assert.Equal(t, want, worryTexts(s, m.Find(s)), "worries in %q", s)
+	}
+}
+
+// TestWorryMatcherUnicode covers matching in any case when lowercasing changes
+// the length of the text, words that restart partway through another, and text
+// that is not valid UTF-8.
+func TestWorryMatcherUnicode(t *testing.T) {
+	m, err := NewWorryMatcher(map[string]WorrySeverity{
+		"istanbul":           WorryInfo,
+		"échec":              WorryErr,
+		"kaput":              WorryWarn,
+		"connection refused": WorryErr,
+		"tcp":                WorryInfo,
+		"datenbankfehler":    WorryCrit,
+	})
+	require.NoError(t, err)
+
+	tests := map[string][]string{
+		"İSTANBUL is down":              {"İSTANBUL:worry-info"},
+		"ÉCHEC, then Échec":             {"ÉCHEC:worry-err", "Échec:worry-err"},
+		"\u212Aaput":                    {"\u212Aaput:worry-warn"},
+		"connection connection refused": {"connection refused:worry-err"},
+		"tcp connection refused":        {"tcp:worry-info", "connection refused:worry-err"},
+		"DATENBANKFEHLER bei ünïcödé":   {"DATENBANKFEHLER:worry-crit"},
+		"\xff\xfeéchec\xff échec":       {"échec:worry-err", "échec:worry-err"},
+		"née-échec":                     {"échec:worry-err"},
+		"nééchec":                       nil,
+	}
+
+	for s, want := range tests {
+		assert.Equal(t, want, worryTexts(s, m.Find(s)), "worries in %q", s)
+	}
+}
+
+// TestNewWorryMatcherInvalidPattern checks that a bad pattern is reported.
+func TestNewWorryMatcherInvalidPattern(t *testing.T) {
+	_, err := NewWorryMatcher(map[string]WorrySeverity{"/5\\d(/": WorryErr})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `/5\d(/`, "error names the pattern")
+}
+
+// TestSetupWorries checks that configured words, phrases, and patterns are
+// compiled into the matcher used for highlighting.
+func TestSetupWorries(t *testing.T) {
+	origWords, origWorries := WorryWords, worries
+	t.Cleanup(func() { WorryWords, worries = origWords, origWorries })
+
+	c := DefaultConfig()
+	c.WorryWords = map[string][]string{
+		"warn": {"slow query"},
+		"crit": {`/exit code [1-9]\d*/`},
+	}
+	require.NoError(t, setupWorries(c))
+
+	s := "slow query, then exit code 137"
+	assert.Equal(t, []string{"slow query:worry-warn", "exit code 137:worry-crit"}, worryTexts(s, findWorries(s)))
+
+	c.WorryWords = map[string][]string{"err": {"/(/"}}
+	assert.Error(t, setupWorries(c), "invalid pattern")
+}
+
+// TestSetupWorriesPerSeverity checks that a severity configured in worries
+// replaces only the built-in words of that severity.
+func TestSetupWorriesPerSeverity(t *testing.T) {
+	origWords, origWorries := WorryWords, worries
+	t.Cleanup(func() { WorryWords, worries = origWords, origWorries })
+
+	c := &Config{WorryWords: map[string][]string{"crit": {"boom"}}}
+	require.NoError(t, setupWorries(c))
+
+	s := "request failed, boom, OOMKilled"
+	assert.Equal(t, []string{"failed:worry-err", "boom:worry-crit"}, worryTexts(s, findWorries(s)), "err kept, crit replaced")
+
+	c.WorryWords = map[string][]string{"severe": {"boom"}}
+	assert.Error(t, setupWorries(c), "unknown severity")
+}
+
+// TestSetupWorriesDefaultConfig checks that the built-in worry words survive
+// loading the configuration when there is no configuration file.
+func TestSetupWorriesDefaultConfig(t *testing.T) {
+	origWords, origWorries := WorryWords, worries
+	t.Cleanup(func() { WorryWords, worries = origWords, origWorries })
+
+	t.Chdir(t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	c, err := LoadConfig()
+	require.NoError(t, err)
+	assert.Contains(t, c.WorryWords["err"], "failed", "built-in words listed by severity")
+	require.NoError(t, setupWorries(c))
+
+	s := "x error failed warning OOMKilled 500 y"
+	assert.Equal(t, []string{
+		"error:worry-err",
+		"failed:worry-err",
+		"warning:worry-warn",
+		"OOMKilled:worry-crit",
+		"500:worry-info",
+	}, worryTexts(s, findWorries(s)), "all the built-in words")
+
+	c.WorryWords = map[string][]string{"err": nil, "warn": {}}
+	require.NoError(t, setupWorries(c))
+	assert.Equal(t, []string{"failed:worry-err", "warning:worry-warn"}, worryTexts("failed warning", findWorries("failed warning")), "severities without words keep the built-ins")
+}
+
+// TestSetupWorriesAddRemove checks that worries_add and worries_remove adjust
+// the built-in worry words rather than replacing them.
+func TestSetupWorriesAddRemove(t *testing.T) {
+	origWords, origWorries := WorryWords, worries
+	t.Cleanup(func() { WorryWords, worries = origWords, origWorries })
+
+	c := DefaultConfig()
+	c.WorriesAdd = map[string][]string{
+		"crit": {"OOMKilled", "panic"},
+		"info": {"Failed"},
+	}
+	c.WorriesRemove = []string{"Invalid", "503"}
+	require.NoError(t, setupWorries(c))
+
+	s := "invalid 503 from panic: failed, error, OOMKilled"
+	assert.Equal(t, []string{
+		"panic:worry-crit",
+		"failed:worry-info",
+		"error:worry-err",
+		"OOMKilled:worry-crit",
+	}, worryTexts(s, findWorries(s)), "defaults kept, words added, moved, and removed")
+
+	c.WorriesAdd = map[string][]string{"severe": {"boom"}}
+	err := setupWorries(c)
+	require.Error(t, err, "unknown severity")
+	assert.Contains(t, err.Error(), "severe", "error names the bad severity")
+}
+
+// benchSyllables are put together into made-up words for the benchmarks.
+var benchSyllables = []string{"ka", "lo", "mi", "ter", "vos", "qu", "ex", "ra", "din", "sol"}
+
+// benchWord makes up a word from the digits of i.
+func benchWord(i int) string {
+	var sb strings.Builder
+	for range 5 {
+		sb.WriteString(benchSyllables[i%len(benchSyllables)])
+		i /= len(benchSyllables)
+	}
+	return sb.String()
+}
+
+// fanoutAlphabet are the bytes following a shared prefix in the fanout test
+// and benchmark.
+const fanoutAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// benchWords returns n made-up worry words, a quarter of them phrases, as a
+// large team word list might be.
+func benchWords(n int) map[string]WorrySeverity {
+	words := maps.Clone(WorryWords)
+	for i := range n {
+		word := benchWord(i)
+		if i%4 == 0 {
+			word += " " + benchWord(i*7+3)
+		}
+		words[word] = WorrySeverity(WorryInfo + i%4)
+	}
+	return words
+}
+
+// benchLines returns n log messages, some with worry words in them.
+func benchLines(n int) []string {
+	r := rand.New(rand.NewPCG(1, 2))
+	templates := []string{
+		"GET /api/v1/users/%d completed in %dms",
+		"request failed: connection refused to 10.0.%d.%d:8080",
+		"cache hit for key %x after %d lookups",
+		"retrying upstream after 503 (attempt %d of %d)",
+		"Ünïcödé user %d logged in from host-%d",
+		"job %[3]s finished, %[1]d of %[2]d items processed",
+	}
+
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf(templates[r.IntN(len(templates))], r.IntN(1000), r.IntN(1000), benchWord(r.IntN(1_000_000)))
+	}
+	return lines
+}
+
+// BenchmarkWorryMatcher finds the worries in 100k log messages, with the
+// built-in worry words and with large lists of custom words.
+func BenchmarkWorryMatcher(b *testing.B) {
+	lines := benchLines(100_000)
+	size := 0
+	for _, line := range lines {
+		size += len(line)
+	}
+
+	for _, n := range []int{0, 1_000, 10_000, 100_000} {
+		m := mustNewWorryMatcher(benchWords(n))
+		b.Run(fmt.Sprintf("%d custom words", n), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			for b.Loop() {
+				for _, line := range lines {
+					m.Find(line)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkWorryMatcherLongMessage finds the worries in single messages of
+// growing length, where the time per byte should stay the same.
+func BenchmarkWorryMatcherLongMessage(b *testing.B) {
+	m := mustNewWorryMatcher(benchWords(10_000))
+	line := strings.Join(benchLines(100), " ")
+
+	for _, size := range []int{1 << 10, 1 << 16, 1 << 20} {
+		msg := strings.Repeat(line, size/len(line)+1)[:size]
+		b.Run(fmt.Sprintf("%d bytes", size), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			for b.Loop() {
+				m.Find(msg)
+			}
+		})
+	}
+}
+
+// BenchmarkWorryMatcherFanout finds worries among many words sharing prefixes,
+// as error codes do, where each node along the way has many edges.
+func BenchmarkWorryMatcherFanout(b *testing.B) {
+	words := maps.Clone(WorryWords)
+	for _, c1 := range fanoutAlphabet {
+		for _, c2 := range fanoutAlphabet {
+			words["code-"+string(c1)+string(c2)] = WorryWarn
+		}
+	}
+	m := mustNewWorryMatcher(words)
+
+	r := rand.New(rand.NewPCG(1, 2))
+	lines := make([]string, 10_000)
+	size := 0
+	for i := range lines {
+		var sb strings.Builder
+		for range 10 {
+			fmt.Fprintf(&sb, "code-%c%c%c ", fanoutAlphabet[r.IntN(len(fanoutAlphabet))], fanoutAlphabet[r.IntN(len(fanoutAlphabet))], "x "[r.IntN(2)])
+		}
+		lines[i] = sb.String()
+		size += len(lines[i])
+	}
+
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	for b.Loop() {
+		for _, line := range lines {
+			m.Find(line)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
-	"bufio"
+	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 type WorrySeverity int
@@ -16,8 +19,8 @@ const (
 	WorryCrit
 )
 
-// WorryWords is the list of words that trigger worry-word highlighting in messages.
-var WorryWords = map[string]WorrySeverity{
+// defaultWorryWords are the built-in worry words, before any configuration.
+var defaultWorryWords = map[string]WorrySeverity{
 	"500":                       WorryInfo,
 	"503":                       WorryInfo,
 	"404":                       WorryInfo,
@@ -32,8 +35,14 @@ var WorryWords = map[string]WorrySeverity{
 	"incorrect":                 WorryInfo,
 	"invalid":                   WorryInfo,
 	"certificate_verify_failed": WorryErr,
+	"connection refused":        WorryErr,
+	"context deadline exceeded": WorryErr,
+	"oomkilled":                 WorryCrit,
 }
 
+// WorryWords is the list of words that trigger worry-word highlighting in messages.
+var WorryWords = maps.Clone(defaultWorryWords)
+
 var worryLevelColors = map[WorrySeverity]ColorName{
 	WorryNone: ColorNormal,
 	WorryInfo: ColorWorryInfo,
@@ -42,92 +51,149 @@ var worryLevelColors = map[WorrySeverity]ColorName{
 	WorryCrit: ColorWorryCritical,
 }
 
-var invertWorryWords map[WorrySeverity][]string
-
-func init() {
-	invertWorryWords = make(map[WorrySeverity][]string)
-	for word, sev := range WorryWords {
-		if _, hasSev := invertWorryWords[sev]; !hasSev {
-			invertWorryWords[sev] = []string{word}
-			continue
+// defaultWorriesOf returns the built-in worry words of the severity, sorted.
+// It reads defaultWorryWords rather than anything set up by an init function,
+// since DefaultConfig is first called from one.
+func defaultWorriesOf(sev WorrySeverity) []string {
+	var words []string
+	for word, s := range defaultWorryWords {
+		if s == sev {
+			words = append(words, word)
 		}
-		invertWorryWords[sev] = append(invertWorryWords[sev], word)
 	}
+	slices.Sort(words)
+	return words
 }
 
-// setupWorries reconfigures the WorryWords, if the Worries are configured.
-func setupWorries(c *Config) {
-	if len(c.WorryWords) == 0 {
-		return
+// setupWorries reconfigures the WorryWords: each severity configured in Worries
+// with words replaces the built-in words of that severity, then the words in
+// WorriesAdd are added and those in WorriesRemove removed. A severity without
+// words keeps the built-ins, as there is no telling it from one left unset.
+// The result is compiled into the matcher.
+func setupWorries(c *Config) error {
+	ww := maps.Clone(defaultWorryWords)
+	for sev, words := range c.WorryWords {
+		wsev, ok := worryWordConfigSeverity[sev]
+		if !ok {
+			return fmt.Errorf("invalid worries severity %q: expected one of none, info, warn, err, crit", sev)
+		}
+		if len(words) == 0 {
+			continue
+		}
+
+		maps.DeleteFunc(ww, func(_ string, s WorrySeverity) bool { return s == wsev })
+		for _, word := range words {
+			removeWorry(ww, word)
+			ww[word] = wsev
+		}
 	}
 
-	ww := make(map[string]WorrySeverity)
-	for sev, words := range c.WorryWords {
+	for sev, words := range c.WorriesAdd {
+		wsev, ok := worryWordConfigSeverity[sev]
+		if !ok {
+			return fmt.Errorf("invalid worries_add severity %q: expected one of none, info, warn, err, crit", sev)
+		}
 		for _, word := range words {
-			ww[word] = worryWordConfigSeverity[sev]
+			removeWorry(ww, word)
+			ww[word] = wsev
 		}
 	}
 
-	if len(ww) == 0 {
-		return
+	for _, word := range c.WorriesRemove {
+		removeWorry(ww, word)
 	}
 
 	WorryWords = ww
+
+	m, err := NewWorryMatcher(WorryWords)
+	if err != nil {
+		return err
+	}
+	worries = m
+	return nil
+}
+
+// removeWorry deletes the word from the worry words, ignoring case, since
+// matching ignores it too.
+func removeWorry(ww map[string]WorrySeverity, word string) {
+	word = strings.TrimSpace(word)
+	maps.DeleteFunc(ww, func(w string, _ WorrySeverity) bool {
+		return strings.EqualFold(strings.TrimSpace(w), word)
+	})
+}
+
+// worryLocations are the accepted --worry-in values, the places where worry
+// words are highlighted: the message, the extracted fields shown below the
+// entry, the values of the trailing fields, and lines that are not log entries.
+var worryLocations = []string{"message", "extracted", "data", "raw"}
+
+// checkWorryIn rejects an unknown --worry-in location.
+func checkWorryIn() error {
+	for _, loc := range worryIn {
+		if !slices.Contains(worryLocations, loc) {
+			return fmt.Errorf("invalid --worry-in location %q: expected one of %s", loc, strings.Join(worryLocations, ", "))
+		}
+	}
+	return nil
+}
+
+// highlightWorriesIn reports whether worry words are highlighted in the
+// location, one of worryLocations.
+func highlightWorriesIn(loc string) bool {
+	return highlightWorryWords && slices.Contains(worryIn, loc)
 }
 
 // IsWordCharacter matches letters, digits, marks, and combining punctuation.
 // That is, given a rune, this returns true if and only if the rune is included
 // in one or more of these Unicode range categories: L, N, M, and Pc.
 func IsWordCharacter(c rune) bool {
+	if c < utf8.RuneSelf {
+		return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_'
+	}
 	return unicode.Is(unicode.L, c) || unicode.Is(unicode.N, c) || unicode.Is(unicode.M, c) || unicode.Is(unicode.Pc, c)
 }
 
-// ScanWordsTheRightWay breaks up on word boundaries. A word boundary is defined
-// as it is for \b in Perl regular expressions, i.e., the strings is split at any
-// boundary between a word character (as defined by \w) and a non-word character
-// (as defined as the negation of \w, a.k.a., \W). The character class of \w
-// matches letters, digits, Unicode marks, and connector punctuation (like
-// underscore). See IsWordCharacter.
-func ScanWordsTheRightWay(
-	data []byte,
-	_ bool,
-) (advance int, token []byte, err error) {
-	if len(data) == 0 {
-		return 0, nil, nil
-	}
+// worrySpan is a worry word found in text, by byte offsets, its severity, and
+// the color of its severity.
+type worrySpan struct {
+	start, end int
+	severity   WorrySeverity
+	color      ColorName
+}
+
+// findWorries finds the worry words in s. See WorryMatcher.
+func findWorries(s string) []worrySpan {
+	return worries.Find(s)
+}
 
-	dataR := []rune(string(data))
-	isWord := IsWordCharacter(dataR[0])
-	for i := range dataR[1:] {
-		if IsWordCharacter(dataR[i]) != isWord {
-			token = []byte(string(dataR[0:i]))
-			advance = len(token)
-			return
+// colorWorries colors the worry words in s by their severity, and the text
+// around them base. An empty base leaves the text around them as it is. Coloring
+// around the worry words, rather than around all of s, keeps the rest of s in
+// base on terminals, where colors do not nest.
+func colorWorries(c *SugaredColorizer, s string, base ColorName) string {
+	sb := &strings.Builder{}
+	around := func(t string) {
+		if t != "" && base != "" {
+			t = c.C(base, t)
 		}
+		sb.WriteString(t)
 	}
 
-	advance = len(data)
-	token = data
-	return
+	last := 0
+	for _, span := range findWorries(s) {
+		around(s[last:span.start])
+		sb.WriteString(c.C(span.color, s[span.start:span.end]))
+		last = span.end
+	}
+	around(s[last:])
+
+	return sb.String()
 }
 
+// HighlightWorries colors the worry words in msg by their severity.
 func HighlightWorries(
 	c *SugaredColorizer,
 	msg string,
 ) string {
-	msgOut := &strings.Builder{}
-	msgReader := strings.NewReader(msg)
-	scanner := bufio.NewScanner(msgReader)
-	scanner.Split(ScanWordsTheRightWay)
-	for scanner.Scan() {
-		word := scanner.Text()
-		worryLevel, ok := WorryWords[strings.ToLower(word)]
-		if ok {
-			color := worryLevelColors[worryLevel]
-			msgOut.WriteString(c.C(color, word))
-		} else {
-			msgOut.WriteString(word)
-		}
-	}
-	return msgOut.String()
+	return colorWorries(c, msg, "")
 }
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFindWorries checks that worry words match whole words in any case,
+// including at the end of the text and before punctuation.
+func TestFindWorries(t *testing.T) {
+	tests := map[string][]string{
+		"request failed.":             {"failed"},
+		"Error: upstream 503":         {"Error", "503"},
+		"failedover, errors, warned":  nil,
+		"certificate_verify_failed":   {"certificate_verify_failed"},
+		"warn":                        {"warn"},
+		"":                            nil,
+		"überfailed failed über":      {"failed"},
+		"invalid\tINVALID\ninvalid!?": {"invalid", "INVALID", "invalid"},
+	}
+
+	for s, want := range tests {
+		var got []string
+		for _, span := range findWorries(s) {
+			got = append(got, s[span.start:span.end])
+		}
+		assert.Equal(t, want, got, "worry words in %q", s)
+	}
+}
+
+// TestColorWorries checks that the text around worry words gets the base
+// color, so the rest of the text keeps its color on a terminal.
+func TestColorWorries(t *testing.T) {
+	c := NewSugaredColorizer(&ColorHTML{})
+
+	assert.Equal(t,
+		`<span class="c-level-error">dial: </span><span class="c-worry-err">failed</span><span class="c-level-error"> twice</span>`,
+		markedToHTML(colorWorries(c, "dial: failed twice", ColorLevelError)), "base around the worry word")
+	assert.Equal(t,
+		`dial: <span class="c-worry-err">failed</span>`,
+		markedToHTML(colorWorries(c, "dial: failed", "")), "no base")
+	assert.Equal(t, `<span class="c-data">all good</span>`, markedToHTML(colorWorries(c, "all good", ColorData)), "no worry words")
+}
+
+// TestCheckWorryIn covers the accepted --worry-in locations.
+func TestCheckWorryIn(t *testing.T) {
+	orig := worryIn
+	t.Cleanup(func() { worryIn = orig })
+
+	worryIn = []string{"message", "extracted", "data", "raw"}
+	assert.NoError(t, checkWorryIn(), "all locations")
+
+	worryIn = nil
+	assert.NoError(t, checkWorryIn(), "no locations")
+
+	worryIn = []string{"message", "fields"}
+	err := checkWorryIn()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"fields"`, "error names the bad location")
+}
+
+// TestWorryIn checks that worry words are highlighted in each location only
+// when it is turned on, and that highlighting the trailing data keeps the rest
+// of the JSON colored.
+func TestWorryIn(t *testing.T) {
+	origWorry, origIn := highlightWorryWords, worryIn
+	t.Cleanup(func() { highlightWorryWords, worryIn = origWorry, origIn })
+	highlightWorryWords = true
+
+	render := func() string {
+		out := &bytes.Buffer{}
+		c := NewSugaredColorizer(&ColorHTML{})
+		lineData := map[string]any{"msg": "request failed", "error": "connection refused: 503", "status": 503.0, "upstream": "cart failed"}
+		outputFormattedLogLine(out, c, lineData, "ts", `{{with index . "msg"}}{{.}}{{end}}`, []string{"msg", "error", "ts"}, &TimeDisplay{})
+		return markedToHTML(out.String())
+	}
+
+	worryIn = []string{"message", "extracted", "data"}
+	page := render()
+	assert.Contains(t, page, `request <span class="c-worry-err">failed</span>`, "message")
+	assert.Contains(t, page, `<span class="c-level-error">    </span><span class="c-worry-err">connection refused</span><span class="c-level-error">: </span><span class="c-worry-info">503</span>`, "extracted")
+	assert.Contains(t, page, `<span class="c-data-key">&#34;status&#34;</span><span class="c-data-punct">:</span><span class="c-worry-info">503</span>`, "data number")
+	assert.Contains(t, page, `<span class="c-data-string">&#34;cart </span><span class="c-worry-err">failed</span><span class="c-data-string">&#34;</span>`, "data string")
+
+	origExpand := expand
+	t.Cleanup(func() { expand = origExpand })
+	expand = "tree"
+	page = render()
+	assert.Contains(t, page, `<span class="c-data-key">upstream</span><span class="c-data">:</span> <span class="c-data-string">cart </span><span class="c-worry-err">failed</span>`, "data tree")
+	expand = "inline"
+
+	worryIn = []string{"message"}
+	page = render()
+	assert.Contains(t, page, `request <span class="c-worry-err">failed</span>`, "message")
+	assert.Contains(t, page, `<span class="c-level-error">    connection refused: 503</span>`, "not extracted")
+	assert.Contains(t, page, `<span class="c-data-number">503</span>`, "not data")
+
+	highlightWorryWords = false
+	worryIn = []string{"message", "extracted", "data", "raw"}
+	assert.NotContains(t, render(), "c-worry-", "turned off everywhere")
+}